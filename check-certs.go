@@ -1,42 +1,550 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
+	"fmt"
 	"go-check-certs/pkg"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
 const (
-	cacheSize     = 50
-	checkInterval = time.Hour * 24
+	cacheSize = 50
+	// hostWarnDays 使用-host且配置中没有warnDays时的告警天数
+	hostWarnDays = 30
 )
 
-var configFile string
+// -once的退出码
+const (
+	exitHealthy  = 0 // 所有证书正常
+	exitWarning  = 1 // 有证书将在warnDays内过期，或有其他告警
+	exitCritical = 2 // 有证书已过期或被吊销，或有host连接失败
+)
+
+var (
+	configFile string
+	once       bool
+	singleHost string
+)
 
 func init() {
 	flag.StringVar(&configFile, "config", "config.yaml", "config file")
-	flag.Parse()
+	flag.StringVar(&singleHost, "host", "", "check only this host (host:port, host:port@servername, hostname/ip:port, smtp://host or file://path), print the result and exit with the same codes as -once, providers and notifies are not used; the config file is optional")
+	flag.BoolVar(&once, "once", false, "run a single check and exit, exit code is 0 when healthy, 1 on warnings, 2 when a certificate expired or a host could not be checked")
+}
+
+// settings 由配置文件生成的运行参数，重新加载配置时整体替换
+type settings struct {
+	config       *pkg.Config
+	rootCAs      *x509.CertPool
+	clientCerts  []tls.Certificate
+	minTLS       uint16
+	handshakeTLS uint16
+	sunsetAlgs   map[x509.SignatureAlgorithm]time.Time
+	filter       *pkg.HostFilter
+	waitTime     time.Duration
+	fingerprints *pkg.FingerprintStore // 重新加载配置时从文件重新读取，未配置path时重新开始记录
+}
+
+func loadSettings(path string) (*settings, error) {
+	config, err := pkg.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+	s := &settings{
+		config:   config,
+		waitTime: config.CheckTimeout() * 10,
+	}
+	if s.minTLS, err = pkg.ParseTLSVersion(config.MinTLSVersion); err != nil {
+		return nil, err
+	}
+	if s.handshakeTLS, err = pkg.ParseTLSVersion(config.HandshakeMinTLSVersion); err != nil {
+		return nil, err
+	}
+	if s.sunsetAlgs, err = pkg.ParseSunsetAlgorithms(config.SunsetAlgorithms); err != nil {
+		return nil, err
+	}
+	if s.filter, err = pkg.NewHostFilter(config.Include, config.Exclude); err != nil {
+		return nil, err
+	}
+	if config.CAFile != "" {
+		if s.rootCAs, err = pkg.LoadCertPool(config.CAFile); err != nil {
+			return nil, fmt.Errorf("load ca file %s: %w", config.CAFile, err)
+		}
+	}
+	if fc := config.Fingerprints; fc != nil {
+		if s.fingerprints, err = pkg.NewFingerprintStore(fc.Path, fc.AlertOnChange); err != nil {
+			return nil, fmt.Errorf("load fingerprints %s: %w", fc.Path, err)
+		}
+	}
+	if config.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate %s: %w", config.ClientCert, err)
+		}
+		s.clientCerts = []tls.Certificate{cert}
+	}
+	return s, nil
 }
 
 func main() {
-	log.Println("DEBUG App start, use config file", configFile)
-	config := pkg.NewConfig(configFile)
-	hostChan := make(chan string, cacheSize)
+	flag.Parse()
+	s, err := loadSettings(configFile)
+	if singleHost != "" && errors.Is(err, fs.ErrNotExist) {
+		// 临时检查一个host时不需要配置文件
+		s, err = &settings{config: &pkg.Config{}}, nil
+	}
+	if err != nil {
+		slog.Error("invalid config, please fix the problems below", "config", configFile)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	pkg.SetupLogger(s.config.LogLevel, s.config.LogFormat)
+	pkg.SetProxy(s.config.Proxy, s.config.NoProxy)
+	pkg.SetProviderConcurrency(s.config.ProviderConcurrency)
+	slog.Debug("app start", "config", configFile)
+	if s.config.MetricsAddr != "" {
+		go pkg.ServeMetrics(s.config.MetricsAddr)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	if singleHost != "" {
+		code := checkSingleHost(ctx, s, singleHost, os.Stdout)
+		stop()
+		os.Exit(code)
+	}
+	if once {
+		code := runOnce(ctx, s)
+		stop()
+		os.Exit(code)
+	}
+	defer stop()
+	health := pkg.NewHealth()
+	health.SetCheckToken(s.config.CheckNowToken())
+	if s.config.HealthAddr != "" {
+		go pkg.ServeHealth(s.config.HealthAddr, health)
+	}
+	state, err := openAlertState(s.config)
+	if err != nil {
+		slog.Error("open alert state failed", "path", s.config.AlertState.Path, "error", err)
+		os.Exit(1)
+	}
+	newDaemon(s, state, health).run(ctx)
+}
+
+func newCheck(s *settings, in <-chan pkg.Host, out chan<- pkg.CheckResult) *pkg.SimpleCheck {
+	check := pkg.NewSimpleCheck(in, out)
+	check.CheckOCSP = s.config.CheckOCSP
+	check.CheckOCSPStapling = s.config.CheckOCSPStapling
+	check.CheckChain = s.config.CheckChain
+	check.CheckHSTS = s.config.CheckHSTS
+	check.MinHSTSMaxAge = time.Duration(s.config.MinHSTSMaxAge) * time.Second
+	check.MinTLSVersion = s.minTLS
+	check.HandshakeMinVersion = s.handshakeTLS
+	check.SunsetCheck = s.config.SunsetCheck
+	check.SunsetAlgs = s.sunsetAlgs
+	check.WildcardLabel = s.config.WildcardLabel
+	check.WildcardApex = s.config.WildcardApex
+	check.Endpoints = s.config.Endpoints
+	check.Thresholds = s.config.Thresholds
+	check.Fingerprints = s.fingerprints
+	check.AlertOnFailure = s.config.AlertOnFailure
+	check.StrictMode = s.config.StrictMode
+	check.Retry = s.config.CheckRetry
+	check.Timeout = s.config.CheckTimeout()
+	check.MinRSABits = s.config.MinRSABits
+	check.MinECDSABits = s.config.MinECDSABits
+	check.RootCAs = s.rootCAs
+	check.ClientCerts = s.clientCerts
+	check.Concurrency = s.config.Concurrency
+	return check
+}
+
+// checkAll 完成一轮完整的检查，结果写入out，全部检查结束或ctx取消后返回，返回本轮最严重的结果对应的退出码。
+// 配置了report时，检查完整结束后把本轮的全部结果写入报告文件；
+// state不为nil时抑制已经通知过的告警，检查完整结束后发送恢复通知；配置了summary时最后发送汇总通知
+func checkAll(ctx context.Context, s *settings, state *pkg.AlertState, out chan<- pkg.CheckResult) int {
+	var report *pkg.Report
+	if s.config.Report != nil {
+		report = pkg.NewReport()
+	}
+	var summary *pkg.Summary
+	if s.config.Summary {
+		summary = pkg.NewSummary()
+	}
 	resChan := make(chan pkg.CheckResult)
-	waitTime := time.Duration(config.Timeout) * 10 * time.Second
-	for _, nc := range config.Notifies {
-		notify := pkg.NewNotify(nc, resChan)
-		go notify.Send(waitTime)
+	done := make(chan struct{})
+	status := exitHealthy
+	go func() {
+		defer close(done)
+		for res := range resChan {
+			status = max(status, resultStatus(res))
+			if report != nil {
+				report.Add(res)
+			}
+			if summary != nil {
+				summary.Add(res)
+			}
+			if state == nil || state.Notify(res) {
+				out <- res
+			} else {
+				out <- pkg.Repeated(res)
+			}
+		}
+	}()
+	check := checkHosts(ctx, s, state, resChan)
+	close(resChan)
+	<-done
+	failures := check.Failures()
+	if failures > 0 {
+		status = exitCritical
+	}
+	if ctx.Err() != nil {
+		return status
+	}
+	if report != nil {
+		writeReport(s.config.Report, report)
+	}
+	if s.fingerprints != nil {
+		if err := s.fingerprints.Save(); err != nil {
+			slog.Error("save fingerprints failed", "path", s.config.Fingerprints.Path, "error", err)
+		}
+	}
+	if state != nil {
+		recovered, err := state.EndCycle()
+		if err != nil {
+			slog.Error("save alert state failed", "path", s.config.AlertState.Path, "error", err)
+		}
+		for _, res := range recovered {
+			out <- res
+		}
+	}
+	if summary != nil {
+		out <- summary.Result(check.Checked(), failures)
+	}
+	return status
+}
+
+// resultStatus 只有SeverityInfo的建议不影响退出码
+func resultStatus(res pkg.CheckResult) int {
+	switch res.Severity {
+	case pkg.SeverityCritical:
+		return exitCritical
+	case pkg.SeverityWarning:
+		return exitWarning
+	default:
+		return exitHealthy
+	}
+}
+
+func writeReport(rc *pkg.ReportConfig, report *pkg.Report) {
+	path, err := report.WriteFile(rc.Path, rc.Format, rc.Rotate, time.Now())
+	if err != nil {
+		slog.Error("write report failed", "path", rc.Path, "error", err)
+		return
+	}
+	slog.Debug("report written", "path", path)
+}
+
+// openAlertState 未配置alertState时返回nil，不抑制重复的告警
+func openAlertState(c *pkg.Config) (*pkg.AlertState, error) {
+	if c.AlertState == nil {
+		return nil, nil
 	}
+	return pkg.NewAlertState(c.AlertState.Path)
+}
+
+// checkHosts 获取所有provider的记录，去重、过滤后检查，返回完成检查的SimpleCheck，用于统计检查和失败的host数量。
+// state用于即将过期告警的graceDays，可以为nil
+func checkHosts(ctx context.Context, s *settings, state *pkg.AlertState, out chan<- pkg.CheckResult) *pkg.SimpleCheck {
+	recordChan := make(chan pkg.Host, cacheSize)
+	uniqueChan := make(chan pkg.Host, cacheSize)
+	hostChan := make(chan pkg.Host, cacheSize)
+	go func() {
+		var pwg sync.WaitGroup
+		for _, pConf := range s.config.Providers {
+			provider := pkg.NewProvider(pConf, s.config.ProviderDefaults)
+			warnDays := s.config.ProviderWarnDays(pConf)
+			pwg.Add(1)
+			go func() {
+				defer pwg.Done()
+				if pkg.GetHosts(ctx, pConf.Name, provider, warnDays, recordChan) > 0 || ctx.Err() != nil {
+					return
+				}
+				slog.Warn("provider returned no records", "provider", pConf.Name)
+				if s.config.FailOnEmptyProvider {
+					out <- pkg.NoRecordsResult(pConf.Name)
+				}
+			}()
+		}
+		pwg.Wait()
+		close(recordChan)
+	}()
+	go pkg.Dedup(ctx, recordChan, uniqueChan, pkg.NewHostSet())
+	go pkg.Filter(ctx, uniqueChan, hostChan, s.filter)
+	check := newCheck(s, hostChan, out)
+	if state != nil {
+		check.AlertState = state
+		check.GraceDays = s.config.AlertState.GraceDays
+	}
+	check.Check(ctx, s.config.WarnDays)
+	if ctx.Err() == nil {
+		check.PruneMetrics()
+	}
+	return check
+}
+
+// notifyGroup 一组运行中的通知，每个通知有独立的输入，由广播把结果发给所有通知
+type notifyGroup struct {
+	wg        sync.WaitGroup
+	delivered chan struct{} // 广播退出后关闭，此时已读出的结果都交给了通知
+}
+
+// Wait 等待ctx取消后广播退出、所有通知发送完剩余消息
+func (ng *notifyGroup) Wait() {
+	ng.wg.Wait()
+}
+
+// startNotifies 启动所有通知和广播，in关闭或ctx取消后广播退出，ctx取消后通知发送完剩余消息再退出
+func startNotifies(ctx context.Context, s *settings, in <-chan pkg.CheckResult) *notifyGroup {
+	ng := &notifyGroup{delivered: make(chan struct{})}
+	outs := make([]chan<- pkg.CheckResult, 0, len(s.config.Notifies))
+	for _, nc := range s.config.Notifies {
+		ch := make(chan pkg.CheckResult)
+		outs = append(outs, ch)
+		notify := pkg.NewNotify(nc, ch)
+		ng.wg.Add(1)
+		go func() {
+			defer ng.wg.Done()
+			notify.Send(ctx, s.waitTime)
+		}()
+	}
+	ng.wg.Add(1)
+	go func() {
+		defer ng.wg.Done()
+		defer close(ng.delivered)
+		pkg.Broadcast(ctx, in, outs)
+	}()
+	return ng
+}
+
+// daemon 周期性检查，收到SIGHUP时重新加载配置
+type daemon struct {
+	mu           sync.Mutex
+	settings     *settings
+	cancelNotify context.CancelFunc
+	notifies     *notifyGroup
+	resChan      chan pkg.CheckResult
+	health       *pkg.Health
+	state        *pkg.AlertState // 跨轮次和重新加载配置保留
+}
+
+func newDaemon(s *settings, state *pkg.AlertState, health *pkg.Health) *daemon {
+	return &daemon{settings: s, resChan: make(chan pkg.CheckResult), health: health, state: state}
+}
+
+func (d *daemon) current() (*settings, *pkg.AlertState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.settings, d.state
+}
+
+// restartNotifies 让旧的通知发送完缓存的消息，再按新配置启动通知，调用方需持有d.mu
+func (d *daemon) restartNotifies(ctx context.Context) {
+	if d.cancelNotify != nil {
+		d.cancelNotify()
+		d.notifies.Wait()
+	}
+	var notifyCtx context.Context
+	notifyCtx, d.cancelNotify = context.WithCancel(ctx)
+	d.notifies = startNotifies(notifyCtx, d.settings, d.resChan)
+}
+
+// reload 重新加载配置，新配置无效时继续使用旧配置。正在进行的检查不受影响，下一轮使用新配置
+func (d *daemon) reload(ctx context.Context) {
+	s, err := loadSettings(configFile)
+	if err != nil {
+		slog.Error("reload config failed, keep using the old config", "config", configFile, "error", err)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// 只有alertState的配置改变时才重新打开，否则保留内存中的状态
+	if !sameAlertState(d.settings.config.AlertState, s.config.AlertState) {
+		state, err := openAlertState(s.config)
+		if err != nil {
+			slog.Error("reload config failed, keep using the old config", "config", configFile, "error", err)
+			return
+		}
+		d.state = state
+	}
+	d.settings = s
+	d.restartNotifies(ctx)
+	pkg.SetupLogger(s.config.LogLevel, s.config.LogFormat)
+	pkg.SetProxy(s.config.Proxy, s.config.NoProxy)
+	pkg.SetProviderConcurrency(s.config.ProviderConcurrency)
+	d.health.SetCheckToken(s.config.CheckNowToken())
+	slog.Info("config reloaded", "config", configFile)
+}
+
+// sameAlertState graceDays在每轮检查时读取，只修改它时不需要重新打开状态
+func sameAlertState(a, b *pkg.AlertStateConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Path == b.Path
+}
+
+func (d *daemon) watchReload(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			d.reload(ctx)
+		}
+	}
+}
+
+// run 启动后立即开始第一轮检查，之后每隔checkInterval开始新的一轮，上一轮超时时结束后立即开始下一轮。
+// 重新加载配置后从下一次等待开始使用新的checkInterval。POST /check在两轮之间额外检查一轮，不改变定时检查的时间
+func (d *daemon) run(ctx context.Context) {
+	d.health.SetAlive(true)
+	defer d.health.SetAlive(false)
+	d.mu.Lock()
+	d.restartNotifies(ctx)
+	d.mu.Unlock()
+	go d.watchReload(ctx)
+	next := time.Now()
 	for {
-		log.Println("DEBUG start new check")
-		for _, pConf := range config.Providers {
-			provider := pkg.NewProvider(pConf)
-			go provider.GetAllRecords(hostChan)
+		select {
+		case <-ctx.Done():
+			slog.Debug("shutting down, waiting for notifies to flush")
+			d.mu.Lock()
+			d.notifies.Wait()
+			d.mu.Unlock()
+			return
+		case <-time.After(time.Until(next)):
+			s, _ := d.current()
+			next = time.Now().Add(s.config.Interval())
+			d.cycle(ctx, false)
+		case <-d.health.CheckRequests():
+			slog.Debug("start requested check")
+			d.cycle(ctx, true)
 		}
-		check := pkg.NewSimpleCheck(hostChan, resChan)
-		check.Check(config.WarnDays)
-		time.Sleep(checkInterval - waitTime)
 	}
 }
+
+// cycle 检查一轮，定时检查和POST /check都在run中依次调用，不会同时进行。
+// manual为true时检查结束后立即发送通知，不等待waitTime
+func (d *daemon) cycle(ctx context.Context, manual bool) {
+	slog.Debug("start new check")
+	s, state := d.current()
+	start := time.Now()
+	d.health.CycleStarted()
+	defer d.health.CycleFinished()
+	checkAll(ctx, s, state, d.resChan)
+	if ctx.Err() == nil {
+		slog.Debug("check cycle finished", "elapsed", time.Since(start))
+		d.health.CycleDone(time.Now())
+		// 标记跟在本轮的结果之后经过广播，通知收到时本轮的结果都已收到
+		select {
+		case d.resChan <- pkg.CycleEnd(manual):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// checkSingleHost 不经过provider和通知，检查一个host并把证书信息和告警打印到w，返回进程退出码
+func checkSingleHost(ctx context.Context, s *settings, host string, w io.Writer) int {
+	warnDays := s.config.WarnDays
+	if warnDays <= 0 {
+		warnDays = hostWarnDays
+	}
+	h := pkg.NewHost(host, warnDays)
+	if h.Host == "" {
+		fmt.Fprintf(w, "invalid host %q\n", host)
+		return 1
+	}
+	in := make(chan pkg.Host, 1)
+	in <- h
+	close(in)
+	out := make(chan pkg.CheckResult)
+	check := newCheck(s, in, out)
+	check.ReportChecked = true
+	// 连接失败也需要打印出来
+	check.AlertOnFailure = true
+	go func() {
+		check.Check(ctx, warnDays)
+		close(out)
+	}()
+	code := exitHealthy
+	printed := false
+	for res := range out {
+		if !printed {
+			fmt.Fprintln(w, res.Host)
+			printed = true
+		}
+		if pkg.IsChecked(res) {
+			fmt.Fprintf(w, "  subject:     %s\n", res.Subject)
+			fmt.Fprintf(w, "  issuer:      %s\n", res.Issuer)
+			fmt.Fprintf(w, "  expires:     %s (%d days)\n", res.NotAfter.Format(time.RFC3339), res.DaysRemaining)
+			fmt.Fprintf(w, "  serial:      %s\n", res.SerialNumber)
+			fmt.Fprintf(w, "  fingerprint: %s\n", res.SHA256Fingerprint)
+			continue
+		}
+		code = max(code, resultStatus(res))
+		fmt.Fprintf(w, "  %s: %s\n", res.Severity, res.WarnMsg)
+	}
+	if code == exitHealthy {
+		fmt.Fprintln(w, "  ok")
+	}
+	return code
+}
+
+// runOnce 完成一次完整的检查并发送通知，返回进程退出码
+func runOnce(ctx context.Context, s *settings) int {
+	state, err := openAlertState(s.config)
+	if err != nil {
+		slog.Error("open alert state failed", "path", s.config.AlertState.Path, "error", err)
+		return 1
+	}
+	resChan := make(chan pkg.CheckResult)
+	notifyChan := make(chan pkg.CheckResult)
+	// 通知使用独立的ctx，检查结束后取消它来触发最后一次发送
+	notifyCtx, cancelNotify := context.WithCancel(context.Background())
+	wg := startNotifies(notifyCtx, s, notifyChan)
+	code := exitHealthy
+	go func() {
+		code = checkAll(ctx, s, state, resChan)
+		close(resChan)
+	}()
+	for res := range resChan {
+		notifyChan <- res
+	}
+	if ctx.Err() == nil {
+		notifyChan <- pkg.CycleEnd(false)
+	}
+	// 等广播把全部结果交给通知后再取消，否则最后的结果可能没有发出
+	close(notifyChan)
+	<-wg.delivered
+	slog.Debug("check finished, flushing notifies")
+	cancelNotify()
+	wg.Wait()
+	return code
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go-check-certs/pkg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDaemon_ManualCycleFlush POST /check触发的一轮结束后立即发送通知，汇总不会被拆到waitTime之后
+func TestDaemon_ManualCycleFlush(t *testing.T) {
+	payloads := make(chan pkg.WebhookPayload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload pkg.WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer srv.Close()
+	s := &settings{
+		config: &pkg.Config{
+			Summary:  true,
+			Notifies: []*pkg.NotifyConfig{{Type: "webhook", Config: map[string]any{"url": srv.URL}}},
+		},
+		waitTime: time.Hour,
+	}
+	d := newDaemon(s, nil, pkg.NewHealth())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.restartNotifies(ctx)
+	for range 20 {
+		d.cycle(ctx, true)
+		select {
+		case payload := <-payloads:
+			if len(payload.Results) != 1 || !strings.HasPrefix(payload.Results[0].WarnMsg, "Checked 0 hosts") {
+				t.Fatalf("unexpected payload %+v", payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("summary of the requested cycle was not sent")
+		}
+	}
+}
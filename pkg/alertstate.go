@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// msgRecovered 之前告警过的host在新一轮检查中没有任何告警时发送一次
+const msgRecovered = "recovered"
+
+var digitsPattern = regexp.MustCompile(`[0-9]+`)
+
+// alertKind 去掉告警信息中的数字，"expires in 9 days"和"expires in 8 days"视为同一种告警，
+// 从按天变为按小时或已过期时种类改变，会重新通知
+func alertKind(warnMsg string) string {
+	return digitsPattern.ReplaceAllString(warnMsg, "N")
+}
+
+// alertKey AlertState中的告警种类，包含Severity，同一种告警升级为更紧急的一级时会重新通知，
+// 例如thresholds中按天的warning和critical两级产生相同的告警信息
+func alertKey(res CheckResult) string {
+	return res.Severity.String() + " " + alertKind(res.WarnMsg)
+}
+
+// IsRecovered 结果是否为恢复通知
+func IsRecovered(res CheckResult) bool {
+	return res.WarnMsg == msgRecovered
+}
+
+// Repeated 标记被alertState抑制的重复告警。它仍然发给通知，PagerDuty和Alertmanager用它维持每轮完整的告警状态，
+// 其他通知不发送
+func Repeated(res CheckResult) CheckResult {
+	res.repeated = true
+	return res
+}
+
+func isRepeated(res CheckResult) bool {
+	return res.repeated
+}
+
+// AlertState 记录已经通知过的(host, 告警种类)，跨轮次保留，抑制重复的通知。
+// 配置了path时每轮结束后写入文件，重启后不会重新通知所有告警
+type AlertState struct {
+	mu      sync.Mutex
+	path    string
+	alerted map[string]map[string]bool // 上一轮结束时处于告警状态的host及告警种类
+	current map[string]map[string]bool // 本轮检查中出现的告警
+}
+
+// NewAlertState path为空时只保存在内存中，文件不存在时从空状态开始
+func NewAlertState(path string) (*AlertState, error) {
+	as := &AlertState{
+		path:    path,
+		alerted: make(map[string]map[string]bool),
+		current: make(map[string]map[string]bool),
+	}
+	if path == "" {
+		return as, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return as, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	saved := make(map[string][]string)
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	for host, kinds := range saved {
+		as.alerted[host] = make(map[string]bool)
+		for _, kind := range kinds {
+			as.alerted[host][kind] = true
+		}
+	}
+	return as, nil
+}
+
+// expiringKinds 即将过期的告警种类
+var expiringKinds = []string{alertKind(fmt.Sprintf(errExpiringSoon, 0)), alertKind(fmt.Sprintf(errExpiringShortly, 0))}
+
+// WasExpiring 上一轮结束时host是否处于任意Severity的即将过期的告警状态
+func (as *AlertState) WasExpiring(host string) bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for key := range as.alerted[host] {
+		if _, kind, _ := strings.Cut(key, " "); slices.Contains(expiringKinds, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify 记录本轮的告警，之前已经通知过同一种告警时返回false
+func (as *AlertState) Notify(res CheckResult) bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	kind := alertKey(res)
+	if as.current[res.Host] == nil {
+		as.current[res.Host] = make(map[string]bool)
+	}
+	as.current[res.Host][kind] = true
+	return !as.alerted[res.Host][kind]
+}
+
+// Failed 记录本轮没能检查的host，保留上一轮的告警状态，不会被当作已恢复，下一轮成功检查时也不会重复通知
+func (as *AlertState) Failed(host string) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if len(as.alerted[host]) == 0 {
+		return
+	}
+	if as.current[host] == nil {
+		as.current[host] = make(map[string]bool)
+	}
+	for kind := range as.alerted[host] {
+		as.current[host][kind] = true
+	}
+}
+
+// EndCycle 在一轮检查完整结束后调用，返回本轮检查正常、没有任何告警的host的恢复通知，并保存状态。
+// 被取消的检查不能调用，否则没来得及检查的host会被当作已恢复
+func (as *AlertState) EndCycle() ([]CheckResult, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	recovered := make([]CheckResult, 0)
+	for host := range as.alerted {
+		if as.current[host] == nil {
+			recovered = append(recovered, CheckResult{Host: host, WarnMsg: msgRecovered, Severity: SeverityInfo})
+		}
+	}
+	sort.Slice(recovered, func(i, j int) bool { return recovered[i].Host < recovered[j].Host })
+	as.alerted, as.current = as.current, make(map[string]map[string]bool)
+	if as.path == "" {
+		return recovered, nil
+	}
+	return recovered, as.save()
+}
+
+func (as *AlertState) save() error {
+	saved := make(map[string][]string, len(as.alerted))
+	for host, kinds := range as.alerted {
+		for kind := range kinds {
+			saved[host] = append(saved[host], kind)
+		}
+		sort.Strings(saved[host])
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(as.path, data)
+}
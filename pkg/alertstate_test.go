@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAlertState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	as, err := NewAlertState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !as.Notify(CheckResult{Host: "a.com:443", WarnMsg: "expires in 9 days"}) {
+		t.Error("first alert was suppressed")
+	}
+	if !as.Notify(CheckResult{Host: "b.com:443", WarnMsg: errExpired}) {
+		t.Error("first alert was suppressed")
+	}
+	if recovered, err := as.EndCycle(); err != nil || len(recovered) != 0 {
+		t.Fatalf("unexpected recovered %v, error %v", recovered, err)
+	}
+
+	// 重启后从文件恢复状态
+	as, err = NewAlertState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as.Notify(CheckResult{Host: "a.com:443", WarnMsg: "expires in 8 days"}) {
+		t.Error("repeated alert was not suppressed")
+	}
+	if !as.Notify(CheckResult{Host: "a.com:443", WarnMsg: "expires in 20 hours"}) {
+		t.Error("more urgent alert was suppressed")
+	}
+	recovered, err := as.EndCycle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 1 || recovered[0].Host != "b.com:443" || !IsRecovered(recovered[0]) {
+		t.Errorf("unexpected recovered %v", recovered)
+	}
+	if !as.Notify(CheckResult{Host: "b.com:443", WarnMsg: errExpired}) {
+		t.Error("alert after recovery was suppressed")
+	}
+}
+
+func TestAlertState_Escalation(t *testing.T) {
+	as, err := NewAlertState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// thresholds: [{days: 30, severity: warning}, {days: 7, severity: critical}]
+	if !as.Notify(CheckResult{Host: "a.com:443", WarnMsg: "expires in 9 days", Severity: SeverityWarning}) {
+		t.Error("first alert was suppressed")
+	}
+	if _, err = as.EndCycle(); err != nil {
+		t.Fatal(err)
+	}
+	if !as.Notify(CheckResult{Host: "a.com:443", WarnMsg: "expires in 6 days", Severity: SeverityCritical}) {
+		t.Error("escalated alert was suppressed")
+	}
+	if _, err = as.EndCycle(); err != nil {
+		t.Fatal(err)
+	}
+	if as.Notify(CheckResult{Host: "a.com:443", WarnMsg: "expires in 5 days", Severity: SeverityCritical}) {
+		t.Error("repeated critical alert was not suppressed")
+	}
+	if !as.WasExpiring("a.com:443") {
+		t.Error("expected a.com to be expiring")
+	}
+}
+
+func TestAlertState_Failed(t *testing.T) {
+	as, err := NewAlertState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	as.Notify(CheckResult{Host: "a.com:443", WarnMsg: errExpired})
+	if _, err = as.EndCycle(); err != nil {
+		t.Fatal(err)
+	}
+	// 本轮连接失败、没有结果的host不算恢复
+	as.Failed("a.com:443")
+	as.Failed("b.com:443")
+	if recovered, _ := as.EndCycle(); len(recovered) != 0 {
+		t.Errorf("failed host reported as recovered: %v", recovered)
+	}
+	if as.Notify(CheckResult{Host: "a.com:443", WarnMsg: errExpired}) {
+		t.Error("alert after a failed check was not suppressed")
+	}
+	if recovered, _ := as.EndCycle(); len(recovered) != 0 {
+		t.Errorf("unexpected recovered %v", recovered)
+	}
+	if recovered, _ := as.EndCycle(); len(recovered) != 1 || recovered[0].Host != "a.com:443" {
+		t.Errorf("unexpected recovered %v", recovered)
+	}
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileScheme 本地证书文件以file://路径的形式进入检查，不建立连接
+const fileScheme = "file"
+
+// certFileExts 目录中按扩展名识别的证书文件
+var certFileExts = map[string]bool{".pem": true, ".crt": true, ".cer": true, ".der": true}
+
+func newCertFileProvider(path string) *CertFileProvider {
+	return &CertFileProvider{path: path}
+}
+
+// CertFileProvider path为文件时只检查该文件，为目录时递归检查其中的证书文件，用于无法联网的环境
+type CertFileProvider struct {
+	path string
+}
+
+func (cp *CertFileProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	root, err := filepath.Abs(cp.path)
+	if err != nil {
+		slog.Warn("read cert files failed", "provider", certFile, "path", cp.path, "error", err)
+		return
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 直接配置的文件不要求扩展名
+		if d.IsDir() || (path != root && !certFileExts[strings.ToLower(filepath.Ext(path))]) {
+			return nil
+		}
+		out <- fileScheme + "://" + path
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		slog.Warn("read cert files failed", "provider", certFile, "path", cp.path, "error", err)
+	}
+}
+
+// parseCertFile 解析PEM或DER格式的证书，PEM文件可以包含完整的证书链，第一张为叶子证书
+func parseCertFile(data []byte) ([]*x509.Certificate, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		// 空文件不会返回错误，但也没有证书
+		certs, err := x509.ParseCertificates(data)
+		if err == nil && len(certs) == 0 {
+			err = errors.New("no certificates found")
+		}
+		return certs, err
+	}
+	certs := make([]*x509.Certificate, 0)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// checkCertFile 对文件中的证书进行与TLS连接相同的有效期、签名算法和公钥检查，结果的Host为文件路径
+func (sc *SimpleCheck) checkCertFile(path string, warnDays int) {
+	slog.Debug("start checking", "file", path)
+	sc.markMetrics(path)
+	data, err := os.ReadFile(path)
+	var certs []*x509.Certificate
+	if err == nil {
+		certs, err = parseCertFile(data)
+	}
+	if err != nil {
+		sc.failures.Add(1)
+		if sc.AlertOnFailure {
+			sc.out <- CheckResult{Host: path, WarnMsg: err.Error(), Severity: SeverityCritical, failed: true}
+		} else {
+			sc.skipFailed(path)
+			slog.Warn("skip check", "file", path, "error", err)
+		}
+		return
+	}
+	now := time.Now()
+	certExpiryDays.WithLabelValues(path).Set(certs[0].NotAfter.Sub(now).Hours() / 24)
+	if sc.ReportChecked {
+		sc.out <- newCertResult(path, msgChecked, SeverityInfo, certs[0], now)
+	}
+	last := certs[len(certs)-1]
+	// 文件中的链以自签名证书结尾时视为根证书
+	hasRoot := len(certs) > 1 && bytes.Equal(last.RawIssuer, last.RawSubject)
+	sc.checkChain(path, certs, hasRoot, warnDays, now)
+	slog.Debug("end checking", "file", path)
+}
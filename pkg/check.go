@@ -1,11 +1,27 @@
 package pkg
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -14,11 +30,139 @@ const (
 	errExpiringSoon    = "expires in %d days"
 	errSunsetAlg       = "expires after the sunset date for its signature algorithm '%s'."
 	errExpired         = "SSLCertificate has expired"
+	errRevoked         = "certificate has been revoked"
+	errHostname        = "hostname not in certificate"
+	errNotYetValid     = "certificate not yet valid, valid in %d hours"
+	errDNSFailed       = "DNS resolution failed: %s"
+	errConnRefused     = "connection refused"
+	errConnFailed      = "connection failed: %s"
+	errHandshake       = "TLS handshake failed: %s"
+	errWeakKey         = "weak key: %s"
+	errIncompleteChain = "server did not send intermediate certificates, sent %d"
+	errUntrusted       = "certificate not trusted by system roots"
+	errHSTS            = "missing or short HSTS max-age: %s"
+	errTLSVersion      = "negotiated %s with %s, below the minimum %s"
+	errTLSRefused      = "server doesn't support %s or later, refused to connect"
+	msgChecked         = "checked"
+	errStapleExpired   = "stapled OCSP response has expired"
+	errStapleExpiring  = "stapled OCSP response expires in %d hours"
+	errStapleInvalid   = "invalid stapled OCSP response: %s"
 )
 
+const (
+	defaultConcurrency  = 50
+	defaultMinRSABits   = 2048
+	defaultMinECDSABits = 256
+	// defaultMinHSTSMaxAge HSTS preload列表要求的最短max-age，一年
+	defaultMinHSTSMaxAge = 365 * 24 * time.Hour
+)
+
+// retryBackoff 第一次重试前的等待时间，之后每次翻倍
+var retryBackoff = 500 * time.Millisecond
+
+// Host 待检查的host，WarnDays为该host所属provider的告警天数，为0时使用全局配置
+type Host struct {
+	Host     string
+	WarnDays int
+	Wildcard bool // 泛域名记录，检查时用WildcardLabel替换*，或设置WildcardApex时连接去掉*.的域名
+}
+
 type CheckResult struct {
-	WarnMsg string
-	Host    string
+	WarnMsg           string
+	Host              string
+	DaysRemaining     int       // 证书剩余有效天数，已过期时为负数
+	NotAfter          time.Time // 证书过期时间，连接失败等与证书无关的结果为零值
+	Issuer            string    // 叶子证书的签发者，连接失败等与证书无关的结果为空
+	Subject           string    // 叶子证书的主题，共用证书的CN可能与host不同，连接失败等结果为空
+	SerialNumber      string    // 叶子证书的序列号，十六进制
+	Severity          Severity  // 用于通知按严重程度过滤或路由，WarnMsg只用于展示
+	SHA256Fingerprint string    // 叶子证书DER编码的SHA-256，十六进制小写
+	marker            marker    // 不为0时不是检查结果，而是随结果一起广播给通知的轮次标记，见CycleEnd
+	repeated          bool      // 被alertState抑制的重复告警，见Repeated
+	failed            bool      // 无法检查的结果，已计入Failures
+}
+
+// Severity 告警的严重程度，数值越大越严重
+type Severity int
+
+const (
+	SeverityInfo     Severity = iota // 与证书有效性无关的建议，例如缺少HSTS
+	SeverityWarning                  // 将在warnDays内过期、签名算法即将淘汰等需要处理的问题
+	SeverityCritical                 // 已过期、被吊销、默认48小时内过期或无法检查
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severityNames {
+		if strings.EqualFold(name, string(text)) {
+			*s = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Threshold 证书剩余时间不到Days天，或按小时计不超过Hours小时时按Severity告警，
+// 按小时的一级告警中剩余时间以小时表示
+type Threshold struct {
+	Days     int      `yaml:"days" json:"days" toml:"days"`
+	Hours    int      `yaml:"hours" json:"hours" toml:"hours"`
+	Severity Severity `yaml:"severity" json:"severity" toml:"severity"`
+}
+
+// window 剩余时间小于window时命中
+func (t Threshold) window() time.Duration {
+	if t.Hours > 0 {
+		return time.Duration(t.Hours+1) * time.Hour
+	}
+	return time.Duration(t.Days) * 24 * time.Hour
+}
+
+func (t Threshold) message(remaining time.Duration) string {
+	hours := int64(remaining.Hours())
+	if t.Hours > 0 {
+		return fmt.Sprintf(errExpiringShortly, hours)
+	}
+	return fmt.Sprintf(errExpiringSoon, hours/24)
+}
+
+func newCertResult(host, warnMsg string, severity Severity, cert *x509.Certificate, now time.Time) CheckResult {
+	return CheckResult{
+		WarnMsg:           warnMsg,
+		Severity:          severity,
+		Host:              host,
+		DaysRemaining:     int(cert.NotAfter.Sub(now).Hours() / 24),
+		NotAfter:          cert.NotAfter,
+		Issuer:            cert.Issuer.String(),
+		Subject:           cert.Subject.String(),
+		SerialNumber:      cert.SerialNumber.Text(16),
+		SHA256Fingerprint: Fingerprint(cert),
+	}
+}
+
+// withLeaf 告警来自中间证书时，签发者、主题和序列号仍使用叶子证书的
+func (r CheckResult) withLeaf(leaf *x509.Certificate) CheckResult {
+	r.Issuer = leaf.Issuer.String()
+	r.Subject = leaf.Subject.String()
+	r.SerialNumber = leaf.SerialNumber.Text(16)
+	r.SHA256Fingerprint = Fingerprint(leaf)
+	return r
 }
 
 type sigAlgSunset struct {
@@ -49,71 +193,740 @@ var sunsetSigAlgs = map[x509.SignatureAlgorithm]sigAlgSunset{
 	},
 }
 
+const (
+	sunsetLeaf = "leaf" // 只检查叶子证书的签名算法
+	sunsetOff  = "off"  // 不检查签名算法
+)
+
+// ParseSunsetAlgorithms 解析算法名到停用日期的映射，算法名为x509的名称，例如SHA1-RSA，日期为2006-01-02格式
+func ParseSunsetAlgorithms(table map[string]string) (map[x509.SignatureAlgorithm]time.Time, error) {
+	if len(table) == 0 {
+		return nil, nil
+	}
+	names := make(map[string]x509.SignatureAlgorithm)
+	for alg := x509.MD2WithRSA; alg <= x509.PureEd25519; alg++ {
+		names[strings.ToUpper(alg.String())] = alg
+	}
+	algs := make(map[x509.SignatureAlgorithm]time.Time, len(table))
+	for name, date := range table {
+		alg, ok := names[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown signature algorithm %q", name)
+		}
+		sunsetsAt, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		algs[alg] = sunsetsAt
+	}
+	return algs, nil
+}
+
+// sunsetAlg 返回签名算法的名称和停用时间，配置了SunsetAlgs时只使用配置的算法
+func (sc *SimpleCheck) sunsetAlg(alg x509.SignatureAlgorithm) (sigAlgSunset, bool) {
+	if sc.SunsetAlgs == nil {
+		s, ok := sunsetSigAlgs[alg]
+		return s, ok
+	}
+	sunsetsAt, ok := sc.SunsetAlgs[alg]
+	return sigAlgSunset{name: alg.String(), sunsetsAt: sunsetsAt}, ok
+}
+
 type HTTPSChecker interface {
-	Check(warnDays int)
+	Check(ctx context.Context, warnDays int)
 }
 
-func NewSimpleCheck(in <-chan string, out chan<- CheckResult) *SimpleCheck {
+func NewSimpleCheck(in <-chan Host, out chan<- CheckResult) *SimpleCheck {
 	return &SimpleCheck{
-		in:  in,
-		out: out,
+		in:        in,
+		out:       out,
+		ocspCache: newOCSPCache(),
 	}
 }
 
 type SimpleCheck struct {
-	in  <-chan string
-	out chan<- CheckResult
+	in        <-chan Host
+	out       chan<- CheckResult
+	ocspCache *ocspCache
+	failures  atomic.Int64
+	checked   atomic.Int64
+	labels    *HostSet // 本轮检查的host在指标中的host标签
+	CheckOCSP bool     // 是否通过OCSP检查证书吊销状态，会额外请求CA的OCSP服务
+	// 检查握手时服务端附带的OCSP响应，有附带时不再单独请求OCSP服务
+	CheckOCSPStapling bool
+	RootCAs           *x509.CertPool // 校验证书使用的根证书，为nil时使用系统根证书
+	Concurrency       int            // 同时进行的TLS连接数上限，为0时使用defaultConcurrency
+	// 连接或握手失败时是否产生告警，默认只记录日志，避免有意下线的host频繁告警
+	AlertOnFailure bool
+	// 证书不是由受信任的根证书签发时，仍然检查服务端发送的证书的有效期、签名算法和公钥，
+	// 并单独报告errUntrusted，用于监控自签名的内部证书。相当于跳过校验，需要显式开启
+	StrictMode   bool
+	Retry        int           // 与证书无关的连接错误的最多尝试次数，为0时使用maxRetry
+	Timeout      time.Duration // 单次建立连接到完成TLS握手的超时时间，为0时使用defaultCheckTimeout
+	MinRSABits   int           // RSA公钥的最小位数，为0时使用defaultMinRSABits
+	MinECDSABits int           // ECDSA曲线的最小位数，为0时使用defaultMinECDSABits
+	// 检查服务端发送的证书链是否缺少中间证书，浏览器会缓存中间证书，其他客户端可能因此校验失败
+	CheckChain bool
+	// 握手时出示的客户端证书，用于要求双向TLS认证的服务，为空时不出示
+	ClientCerts []tls.Certificate
+	// 握手后在同一个连接上发送一次HTTP请求，检查Strict-Transport-Security响应头，只用于HTTPS
+	CheckHSTS     bool
+	MinHSTSMaxAge time.Duration // HSTS max-age的最小值，为0时使用defaultMinHSTSMaxAge
+	MinTLSVersion uint16        // 协商的TLS版本低于该版本时告警，为0时使用TLS 1.2
+	// 签名算法停用检查的范围，leaf只检查叶子证书，off不检查，为空时检查根证书以外的全部证书
+	SunsetCheck string
+	SunsetAlgs  map[x509.SignatureAlgorithm]time.Time // 签名算法的停用时间，为nil时使用sunsetSigAlgs
+	// 握手时接受的最低TLS版本，为0时接受TLS 1.0，由MinTLSVersion报告版本过旧。
+	// 设置后不支持该版本的host握手失败，报告为errTLSRefused
+	HandshakeMinVersion uint16
+	// 记录每个host的叶子证书指纹，为nil时不检查证书是否在计划外被更换
+	Fingerprints *FingerprintStore
+	// 每个成功检查的host先输出一个msgChecked结果，带有叶子证书的信息，用于命令行直接展示
+	ReportChecked bool
+	// 返回连接目标使用的代理，为nil时使用SetProxy配置的代理或环境变量
+	Proxy func(target *url.URL) (*url.URL, error)
+	// 检查泛域名记录时替代*的label，为空时使用每次运行随机生成的label
+	WildcardLabel string
+	// 泛域名记录*.example.com连接example.com而不是解析label.example.com，SNI仍使用label.example.com，
+	// 只有泛域名证书能通过校验。无论是否设置，结果中的host都为*.example.com
+	WildcardApex bool
+	// hostname到实际拨号地址host:port的映射，SNI、证书校验和结果仍使用hostname，不在其中的host按默认方式连接
+	Endpoints map[string]string
+	// 上一轮在AlertState中处于即将过期告警的host，剩余天数超过warnDays+GraceDays才解除告警，
+	// AlertState为nil或GraceDays为0时不使用
+	AlertState *AlertState
+	GraceDays  int
+	// 即将过期的告警等级，命中多级时使用剩余时间范围最小的一级。为空时在warnDays内告警，
+	// 48小时内为严重告警；不为空时不再使用warnDays判断过期
+	Thresholds []Threshold
+}
+
+// IsChecked 结果是否为ReportChecked输出的证书信息，而不是告警
+func IsChecked(res CheckResult) bool {
+	return res.WarnMsg == msgChecked
+}
+
+// Failures 连接或握手失败、没能检查证书的host数量，不受AlertOnFailure影响
+func (sc *SimpleCheck) Failures() int64 {
+	return sc.failures.Load()
+}
+
+// Checked 已经完成检查的host数量，包括连接失败的host
+func (sc *SimpleCheck) Checked() int64 {
+	return sc.checked.Load()
+}
+
+// markMetrics 记录本轮写入指标时使用的host标签，不在Check中运行时不记录
+func (sc *SimpleCheck) markMetrics(label string) {
+	if sc.labels != nil {
+		sc.labels.Add(label)
+	}
+}
+
+// PruneMetrics 删除本轮没有检查的host的指标，例如从provider或过滤规则中移除的host，只在完整的一轮检查后调用
+func (sc *SimpleCheck) PruneMetrics() {
+	if sc.labels != nil {
+		pruneHostMetrics(sc.labels.hosts())
+	}
+}
+
+// LoadCertPool 在系统根证书的基础上追加PEM格式的CA证书，用于校验内部PKI签发的证书
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
 }
 
-func (sc *SimpleCheck) Check(warnDays int) {
-	go func() {
-		for host := range sc.in {
-			go sc.checkHostHttps(host, warnDays)
+// Check 消费in中的host并检查，直到in被关闭或ctx取消，返回前等待已开始的检查完成。
+// warnDays用于没有单独设置告警天数的host
+func (sc *SimpleCheck) Check(ctx context.Context, warnDays int) {
+	concurrency := sc.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	sc.labels = NewHostSet()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		var host Host
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case host, ok = <-sc.in:
+			if !ok {
+				return
+			}
 		}
-	}()
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(host Host) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer sc.checked.Add(1)
+			days := host.WarnDays
+			if days == 0 {
+				days = warnDays
+			}
+			if host.Wildcard {
+				slog.Debug("check wildcard record", "host", host.Host, "label", sc.wildcardLabel())
+			}
+			if scheme, path := splitScheme(host.Host); scheme == fileScheme {
+				sc.checkCertFile(path, days)
+			} else {
+				sc.checkHostHttps(ctx, host.Host, days)
+			}
+		}(host)
+	}
 }
 
-func (sc *SimpleCheck) checkHostHttps(host string, warnDays int) {
-	if host == "" || host[0] == '@' {
-		return
+// target 待检查的地址，addr为拨号地址，serverName为TLS握手使用的SNI，
+// scheme为STARTTLS协议时先按该协议协商，wildcard不为空时为结果中展示的泛域名
+type target struct {
+	addr       string
+	serverName string
+	scheme     string
+	wildcard   string
+}
+
+// parseTarget 解析host，支持host、host:port以及host:port@servername指定SNI，未指定端口时使用443。
+// hostname/1.2.3.4:443连接负载均衡后面的某个节点，拨号地址为IP，SNI和证书校验使用hostname。
+// 可以用smtp://、imap://、pop3://、ftp://前缀指定STARTTLS协议，smtps://等前缀直接进行TLS握手，未指定端口时使用
+// 协议的默认端口；没有前缀时25、587、143、110、21端口自动使用STARTTLS
+// 泛域名*.example.com中的*替换为label，apex为true时拨号地址改为example.com，单独的*没有可以连接的域名，返回空的target
+func parseTarget(host, label string, apex bool) target {
+	scheme, host := splitScheme(host)
+	dial, serverName := host, ""
+	if i := strings.LastIndex(host, "@"); i > 0 {
+		dial, serverName = host[:i], host[i+1:]
+	}
+	if name, addr, ok := splitNodeAddr(dial); ok {
+		dial = addr
+		if serverName == "" {
+			serverName = name
+		}
+	}
+	hostname, port, err := net.SplitHostPort(dial)
+	if err != nil {
+		hostname, port = strings.Trim(dial, "[]"), "443"
+		if p, ok := schemePorts[scheme]; ok {
+			port = p
+		}
 	}
-	values := strings.Split(host, ":")
-	if len(values) == 1 {
-		host = fmt.Sprintf("%s:443", host)
+	if hostname == "*" {
+		return target{}
 	}
-	if host[0] == '*' {
-		// *为泛域名解析，需要指定一个字符串来替换它
-		host = fmt.Sprintf("%s%s:443", "abcdefzhki", host[1:])
+	// 结果中保留泛域名，不展示每次运行可能不同的label
+	wildcard := serverName
+	if wildcard == "" {
+		wildcard = hostname
 	}
-	conn, err := tls.Dial("tcp", host, nil)
+	if !strings.HasPrefix(wildcard, "*") {
+		wildcard = ""
+	}
+	if apex && strings.HasPrefix(hostname, "*.") {
+		if serverName == "" {
+			serverName = hostname
+		}
+		hostname = strings.TrimPrefix(hostname, "*.")
+	}
+	hostname = expandWildcard(hostname, label)
+	serverName = expandWildcard(serverName, label)
+	if serverName == "" {
+		serverName = hostname
+	}
+	if scheme == "" {
+		scheme = starttlsPorts[port]
+	}
+	return target{addr: net.JoinHostPort(hostname, port), serverName: serverName, scheme: scheme, wildcard: wildcard}
+}
+
+// endpoint Endpoints中有hostname时改为连接映射的地址，没有端口时使用原来的端口。
+// 已经用hostname/ip或@servername指定了连接地址的host不使用Endpoints
+func (sc *SimpleCheck) endpoint(t target) target {
+	hostname, port, err := net.SplitHostPort(t.addr)
+	if err != nil || hostname != t.serverName {
+		return t
+	}
+	addr, ok := sc.Endpoints[hostname]
+	if !ok {
+		return t
+	}
+	if _, _, err = net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, port)
+	}
+	slog.Debug("dial endpoint override", "host", t.addr, "endpoint", addr)
+	t.addr = addr
+	return t
+}
+
+// splitNodeAddr 拆分hostname/ip:port，不包含/时ok为false
+func splitNodeAddr(host string) (name, addr string, ok bool) {
+	i := strings.Index(host, "/")
+	if i <= 0 || i == len(host)-1 {
+		return "", host, false
+	}
+	return host[:i], host[i+1:], true
+}
+
+// expandWildcard 把开头的*.或*替换为label.，其他hostname原样返回
+func expandWildcard(hostname, label string) string {
+	if !strings.HasPrefix(hostname, "*") {
+		return hostname
+	}
+	return label + "." + strings.TrimPrefix(strings.TrimPrefix(hostname, "*"), ".")
+}
+
+// newWildcardLabel 每次运行随机生成，避免与真实存在的子域名冲突
+func newWildcardLabel() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return "check-certs-" + hex.EncodeToString(b)
+}
+
+var defaultWildcardLabel = newWildcardLabel()
+
+// wildcardLabel 未配置WildcardLabel时使用本次运行随机生成的label
+func (sc *SimpleCheck) wildcardLabel() string {
+	if sc.WildcardLabel != "" {
+		return sc.WildcardLabel
+	}
+	return defaultWildcardLabel
+}
+
+// String 用于结果展示，SNI与拨号地址不同时一并显示，STARTTLS时带上协议前缀。
+// 泛域名记录展示为*.example.com，同一条记录每次运行的结果相同
+func (t target) String() string {
+	s := t.addr
+	hostname, port, _ := net.SplitHostPort(t.addr)
+	switch {
+	case t.wildcard != "" && (hostname == t.serverName || "*."+hostname == t.wildcard):
+		s = net.JoinHostPort(t.wildcard, port)
+	case t.wildcard != "":
+		s += "@" + t.wildcard
+	case hostname != t.serverName:
+		s += "@" + t.serverName
+	}
+	if t.scheme != "" {
+		s = t.scheme + "://" + s
+	}
+	return s
+}
+
+func (sc *SimpleCheck) timeout() time.Duration {
+	if sc.Timeout > 0 {
+		return sc.Timeout
+	}
+	return defaultCheckTimeout
+}
+
+// dialTCP 建立到addr的TCP连接，需要使用代理时通过代理连接
+func (sc *SimpleCheck) dialTCP(ctx context.Context, d *net.Dialer, addr string) (net.Conn, error) {
+	lookup := sc.Proxy
+	if lookup == nil {
+		lookup = lookupProxy
+	}
+	proxyURL, err := lookup(&url.URL{Scheme: "https", Host: addr})
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	return dialProxy(ctx, d, proxyURL, addr)
+}
+
+// dial 建立TLS连接，需要STARTTLS时先在明文连接上完成协商，整个过程不超过sc.Timeout
+func (sc *SimpleCheck) dial(ctx context.Context, t target) (*tls.Conn, error) {
+	timeout := sc.timeout()
+	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	netDialer := &net.Dialer{Timeout: timeout}
+	// Go默认不协商TLS 1.2以下的版本，放开限制才能发现仍在使用旧版本的host
+	minVersion := sc.HandshakeMinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS10
+	}
+	config := &tls.Config{ServerName: t.serverName, RootCAs: sc.RootCAs, Certificates: sc.ClientCerts, MinVersion: minVersion}
+	rawConn, err := sc.dialTCP(ctx, netDialer, t.addr)
 	if err != nil {
-		if strings.Contains(err.Error(), "certificate has expired") {
-			sc.out <- CheckResult{Host: host, WarnMsg: errExpired}
+		return nil, err
+	}
+	if err = rawConn.SetDeadline(deadline); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	if t.scheme != "" && !implicitTLSSchemes[t.scheme] {
+		if err = starttls(rawConn, t.scheme); err != nil {
+			rawConn.Close()
+			return nil, err
+		}
+	}
+	conn := tls.Client(rawConn, config)
+	if err = conn.HandshakeContext(ctx); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	if err = rawConn.SetDeadline(time.Time{}); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (sc *SimpleCheck) checkHostHttps(ctx context.Context, host string, warnDays int) {
+	if !checkable(host) {
+		return
+	}
+	t := parseTarget(host, sc.wildcardLabel(), sc.WildcardApex)
+	host = t.String()
+	sc.markMetrics(host)
+	t = sc.endpoint(t)
+	conn, err := sc.dialWithRetry(ctx, t)
+	if err != nil {
+		checkFailures.WithLabelValues(host).Inc()
+		var hostnameErr x509.HostnameError
+		var invalidErr x509.CertificateInvalidError
+		if errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired {
+			// 证书尚未生效时x509同样返回Expired
+			now := time.Now()
+			if now.Before(invalidErr.Cert.NotBefore) {
+				sc.out <- notYetValidResult(host, invalidErr.Cert, now)
+			} else {
+				certExpiryDays.WithLabelValues(host).Set(invalidErr.Cert.NotAfter.Sub(now).Hours() / 24)
+				sc.out <- newCertResult(host, errExpired, SeverityCritical, invalidErr.Cert, now)
+			}
+		} else if strings.Contains(err.Error(), "certificate has expired") {
+			sc.out <- CheckResult{Host: host, WarnMsg: errExpired, Severity: SeverityCritical}
+		} else if errors.As(err, &hostnameErr) {
+			sc.out <- CheckResult{Host: host, WarnMsg: errHostname, Severity: SeverityWarning}.withLeaf(hostnameErr.Certificate)
+		} else if res, ok := sc.missingIntermediatesResult(host, err); ok {
+			sc.out <- res
+		} else if served, ok := sc.untrustedCerts(err); ok {
+			sc.checkUntrusted(host, t.serverName, served, warnDays)
+		} else if sc.HandshakeMinVersion != 0 && isVersionRefused(err) {
+			// 与握手失败不同，这是配置的策略，不受AlertOnFailure影响；未配置时按普通的握手失败处理
+			sc.failures.Add(1)
+			sc.out <- CheckResult{Host: host, WarnMsg: fmt.Sprintf(errTLSRefused, tls.VersionName(sc.HandshakeMinVersion)), Severity: SeverityCritical, failed: true}
+		} else if sc.AlertOnFailure {
+			sc.failures.Add(1)
+			sc.out <- failureResult(host, err)
 		} else {
-			log.Println("WARN skip check", host, err)
+			sc.failures.Add(1)
+			sc.skipFailed(host)
+			slog.Warn("skip check", "host", host, "error", err)
 		}
 		return
 	}
 	defer conn.Close()
 	timeNow := time.Now()
+	if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		certExpiryDays.WithLabelValues(host).Set(certs[0].NotAfter.Sub(timeNow).Hours() / 24)
+		if sc.ReportChecked {
+			sc.out <- newCertResult(host, msgChecked, SeverityInfo, certs[0], timeNow)
+		}
+		if certs[0].VerifyHostname(t.serverName) != nil {
+			sc.out <- CheckResult{Host: host, WarnMsg: errHostname, Severity: SeverityWarning}.withLeaf(certs[0])
+		}
+		if sc.Fingerprints != nil {
+			sc.checkFingerprint(host, certs[0], warnDays, timeNow)
+		}
+	}
 	for _, chain := range conn.ConnectionState().VerifiedChains {
-		for certNum, cert := range chain {
-			// Check the expiration.
-			if timeNow.AddDate(0, 0, warnDays).After(cert.NotAfter) {
-				expiresIn := int64(cert.NotAfter.Sub(timeNow).Hours())
-				if expiresIn <= 48 {
-					sc.out <- CheckResult{Host: host, WarnMsg: fmt.Sprintf(errExpiringShortly, expiresIn)}
-				} else {
-					sc.out <- CheckResult{Host: host, WarnMsg: fmt.Sprintf(errExpiringSoon, expiresIn/24)}
-				}
+		sc.checkChain(host, chain, true, warnDays, timeNow)
+	}
+	if state := conn.ConnectionState(); sc.CheckChain && len(state.VerifiedChains) > 0 && !servedChainComplete(state.PeerCertificates, state.VerifiedChains) {
+		sc.out <- newCertResult(host, fmt.Sprintf(errIncompleteChain, len(state.PeerCertificates)), SeverityWarning, state.PeerCertificates[0], timeNow)
+	}
+	stapled := sc.CheckOCSPStapling && sc.checkStapledOCSP(host, conn.ConnectionState())
+	if chains := conn.ConnectionState().VerifiedChains; sc.CheckOCSP && !stapled && len(chains) > 0 && len(chains[0]) > 1 {
+		sc.checkRevocation(host, chains[0][0], chains[0][1])
+	}
+	sc.checkTLSVersion(host, conn.ConnectionState())
+	if sc.CheckHSTS && t.scheme == "" {
+		sc.checkHSTS(conn, host, t.serverName)
+	}
+	slog.Debug("end checking", "host", host)
+}
+
+// skipFailed 不通知的连接失败没有结果，在AlertState中保留该host的告警状态，避免误报恢复
+func (sc *SimpleCheck) skipFailed(host string) {
+	if sc.AlertState != nil {
+		sc.AlertState.Failed(host)
+	}
+}
+
+// checkChain 检查证书链中每张证书的有效期、签名算法和公钥长度，结果中的签发者等信息取自chain[0]。
+// hasRoot为true时最后一张为根证书，不检查它的签名算法和公钥
+func (sc *SimpleCheck) checkChain(host string, chain []*x509.Certificate, hasRoot bool, warnDays int, now time.Time) {
+	graceDays := sc.expiryGraceDays(host)
+	if now.Before(chain[0].NotBefore) {
+		sc.out <- notYetValidResult(host, chain[0], now)
+	}
+	for certNum, cert := range chain {
+		isRoot := hasRoot && certNum == len(chain)-1
+		// Check the expiration.
+		if now.After(cert.NotAfter) {
+			sc.out <- newCertResult(host, errExpired, SeverityCritical, cert, now).withLeaf(chain[0])
+		} else if t, ok := sc.expiryThreshold(cert.NotAfter.Sub(now), warnDays, graceDays); ok {
+			sc.out <- newCertResult(host, t.message(cert.NotAfter.Sub(now)), t.Severity, cert, now).withLeaf(chain[0])
+		}
+		// Check the signature algorithm, ignoring the root certificate.
+		if alg, ok := sc.sunsetAlg(cert.SignatureAlgorithm); ok && !isRoot && sc.SunsetCheck != sunsetOff && (certNum == 0 || sc.SunsetCheck != sunsetLeaf) {
+			if cert.NotAfter.Equal(alg.sunsetsAt) || cert.NotAfter.After(alg.sunsetsAt) {
+				sc.out <- newCertResult(host, fmt.Sprintf(errSunsetAlg, alg.name), SeverityWarning, cert, now).withLeaf(chain[0])
 			}
-			// Check the signature algorithm, ignoring the root certificate.
-			if alg, ok := sunsetSigAlgs[cert.SignatureAlgorithm]; ok && certNum != len(chain)-1 {
-				if cert.NotAfter.Equal(alg.sunsetsAt) || cert.NotAfter.After(alg.sunsetsAt) {
-					sc.out <- CheckResult{WarnMsg: fmt.Sprintf(errSunsetAlg, alg.name), Host: host}
-				}
+		}
+		// Check the public key size, also ignoring the root certificate.
+		if weak := sc.weakKey(cert); weak != "" && !isRoot {
+			sc.out <- newCertResult(host, fmt.Sprintf(errWeakKey, weak), SeverityWarning, cert, now).withLeaf(chain[0])
+		}
+	}
+}
+
+// expiryGraceDays 证书有效期在warnDays附近续期时，避免告警和恢复在相邻的两轮之间反复出现
+func (sc *SimpleCheck) expiryGraceDays(host string) int {
+	if sc.GraceDays > 0 && sc.AlertState != nil && sc.AlertState.WasExpiring(host) {
+		return sc.GraceDays
+	}
+	return 0
+}
+
+// expiryThreshold 返回剩余时间命中的范围最小的一级，graceDays只加在范围最大的一级上
+func (sc *SimpleCheck) expiryThreshold(remaining time.Duration, warnDays, graceDays int) (Threshold, bool) {
+	thresholds := sc.Thresholds
+	if len(thresholds) == 0 {
+		thresholds = []Threshold{{Days: warnDays, Severity: SeverityWarning}}
+		// warnDays很小时critical一级的范围超过了warnDays，不再使用，避免产生warnDays之外的告警
+		if critical := (Threshold{Hours: 48, Severity: SeverityCritical}); critical.window() < thresholds[0].window() {
+			thresholds = append(thresholds, critical)
+		}
+	}
+	var widest time.Duration
+	for _, t := range thresholds {
+		widest = max(widest, t.window())
+	}
+	var match Threshold
+	found := false
+	for _, t := range thresholds {
+		window := t.window()
+		if window == widest {
+			window += time.Duration(graceDays) * 24 * time.Hour
+		}
+		if remaining < window && (!found || t.window() < match.window()) {
+			match, found = t, true
+		}
+	}
+	return match, found
+}
+
+// servedChainComplete 服务端发送的证书包含任意一条校验通过的证书链中除根证书外的全部证书。
+// 缺少的中间证书在RootCAs或系统证书中时校验仍能通过，只有对比才能发现
+func servedChainComplete(served []*x509.Certificate, chains [][]*x509.Certificate) bool {
+	for _, chain := range chains {
+		if len(chain) <= 2 {
+			return true
+		}
+		complete := true
+		for _, cert := range chain[1 : len(chain)-1] {
+			if !slices.ContainsFunc(served, cert.Equal) {
+				complete = false
+				break
 			}
 		}
+		if complete {
+			return true
+		}
+	}
+	return false
+}
+
+// missingIntermediatesResult 服务端只发送了非自签名的叶子证书导致找不到签发者时，报告缺少中间证书而不是校验失败
+func (sc *SimpleCheck) missingIntermediatesResult(host string, err error) (CheckResult, bool) {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	if !sc.CheckChain || !errors.As(err, &verifyErr) || !errors.As(err, &authorityErr) {
+		return CheckResult{}, false
+	}
+	served := verifyErr.UnverifiedCertificates
+	if len(served) != 1 || bytes.Equal(served[0].RawIssuer, served[0].RawSubject) {
+		return CheckResult{}, false
+	}
+	return newCertResult(host, fmt.Sprintf(errIncompleteChain, len(served)), SeverityWarning, served[0], time.Now()), true
+}
+
+// untrustedCerts StrictMode时返回因签发者不受信任而没有通过校验的证书链，即服务端发送的全部证书，
+// 与InsecureSkipVerify握手得到的证书相同，不需要重新连接
+func (sc *SimpleCheck) untrustedCerts(err error) ([]*x509.Certificate, bool) {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	if !sc.StrictMode || !errors.As(err, &verifyErr) || !errors.As(err, &authorityErr) || len(verifyErr.UnverifiedCertificates) == 0 {
+		return nil, false
+	}
+	return verifyErr.UnverifiedCertificates, true
+}
+
+// checkUntrusted 报告证书不受信任，再像校验通过的证书一样检查服务端发送的证书链，链中没有受信任的根证书
+func (sc *SimpleCheck) checkUntrusted(host, serverName string, served []*x509.Certificate, warnDays int) {
+	now := time.Now()
+	certExpiryDays.WithLabelValues(host).Set(served[0].NotAfter.Sub(now).Hours() / 24)
+	sc.out <- newCertResult(host, errUntrusted, SeverityWarning, served[0], now)
+	if served[0].VerifyHostname(serverName) != nil {
+		sc.out <- CheckResult{Host: host, WarnMsg: errHostname, Severity: SeverityWarning}.withLeaf(served[0])
+	}
+	sc.checkChain(host, served, false, warnDays, now)
+}
+
+// tlsVersions 配置中可以使用的TLS版本
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion 解析1.0、1.1、1.2、1.3形式的版本号，为空时返回0
+func ParseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToUpper(version), "TLS")]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version %q", version)
+	}
+	return v, nil
+}
+
+// checkTLSVersion 记录协商的TLS版本和加密套件，版本低于MinTLSVersion时告警
+func (sc *SimpleCheck) checkTLSVersion(host string, state tls.ConnectionState) {
+	version, cipher := tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)
+	slog.Debug("negotiated", "host", host, "version", version, "cipher", cipher)
+	tlsNegotiated.DeletePartialMatch(prometheus.Labels{"host": host})
+	tlsNegotiated.WithLabelValues(host, version, cipher).Set(1)
+	minVersion := sc.MinTLSVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	if state.Version < minVersion {
+		res := CheckResult{Host: host, WarnMsg: fmt.Sprintf(errTLSVersion, version, cipher, tls.VersionName(minVersion)), Severity: SeverityWarning}
+		if len(state.PeerCertificates) > 0 {
+			res = res.withLeaf(state.PeerCertificates[0])
+		}
+		sc.out <- res
+	}
+}
+
+// failureResult 区分DNS解析失败、连接被拒绝、其他连接错误和TLS握手错误
+func failureResult(host string, err error) CheckResult {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return CheckResult{Host: host, WarnMsg: fmt.Sprintf(errDNSFailed, dnsErr.Err), Severity: SeverityCritical, failed: true}
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return CheckResult{Host: host, WarnMsg: errConnRefused, Severity: SeverityCritical, failed: true}
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return CheckResult{Host: host, WarnMsg: fmt.Sprintf(errConnFailed, opErr.Err), Severity: SeverityCritical, failed: true}
+	default:
+		return CheckResult{Host: host, WarnMsg: fmt.Sprintf(errHandshake, err), Severity: SeverityCritical, failed: true}
+	}
+}
+
+// dialWithRetry 网络错误时按指数退避重试，证书校验错误是确定的，直接返回
+func (sc *SimpleCheck) dialWithRetry(ctx context.Context, t target) (*tls.Conn, error) {
+	attempts := sc.Retry
+	if attempts <= 0 {
+		attempts = maxRetry
+	}
+	backoff := retryBackoff
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			slog.Debug("retry check", "host", t.String(), "attempt", i+1, "error", err)
+			select {
+			case <-ctx.Done():
+				return nil, err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		var conn *tls.Conn
+		if conn, err = sc.dial(ctx, t); err == nil {
+			return conn, nil
+		}
+		if isCertificateError(err) || isVersionRefused(err) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// isVersionRefused 设置了HandshakeMinVersion后，服务端只支持更低版本时返回protocol_version告警，
+// 或选择了客户端不接受的版本
+func isVersionRefused(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "protocol version not supported") || strings.Contains(msg, "server selected unsupported protocol version")
+}
+
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+	return errors.As(err, &verifyErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &authorityErr) ||
+		strings.Contains(err.Error(), "certificate has expired")
+}
+
+// weakKey 公钥低于配置的最小位数时返回描述，例如"RSA 1024 bits"，否则返回空
+func (sc *SimpleCheck) weakKey(cert *x509.Certificate) string {
+	minRSA, minECDSA := sc.MinRSABits, sc.MinECDSABits
+	if minRSA <= 0 {
+		minRSA = defaultMinRSABits
+	}
+	if minECDSA <= 0 {
+		minECDSA = defaultMinECDSABits
+	}
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < minRSA {
+			return fmt.Sprintf("RSA %d bits", bits)
+		}
+	case *ecdsa.PublicKey:
+		if params := key.Curve.Params(); params.BitSize < minECDSA {
+			return "ECDSA " + params.Name
+		}
+	}
+	return ""
+}
+
+func notYetValidResult(host string, cert *x509.Certificate, now time.Time) CheckResult {
+	validIn := int64(cert.NotBefore.Sub(now).Hours())
+	return newCertResult(host, fmt.Sprintf(errNotYetValid, validIn), SeverityCritical, cert, now)
+}
+
+func (sc *SimpleCheck) checkRevocation(host string, cert, issuer *x509.Certificate) {
+	status, err := sc.ocspCache.status(cert, issuer)
+	if err != nil {
+		slog.Warn("skip OCSP check", "host", host, "error", err)
+		return
+	}
+	if status == ocsp.Revoked {
+		sc.out <- newCertResult(host, errRevoked, SeverityCritical, cert, time.Now())
 	}
-	log.Println("DEBUG end checking", host)
 }
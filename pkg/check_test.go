@@ -1,7 +1,1082 @@
 package pkg
 
-import "testing"
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/crypto/ocsp"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestCheckServer_Check(t *testing.T) {
 
 }
+
+// newTestCert 生成一张自签名证书，用于本地TLS服务
+func newTestCert(t *testing.T, dnsNames []string, notBefore, notAfter time.Time) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:              dnsNames,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// startTLSServer 启动一个完成握手后即关闭连接的TLS服务，返回监听地址
+func startTLSServer(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// collectResults 读取out中的结果，直到超过timeout没有新结果
+func collectResults(out <-chan CheckResult, timeout time.Duration) []CheckResult {
+	results := make([]CheckResult, 0)
+	for {
+		select {
+		case res := <-out:
+			results = append(results, res)
+		case <-time.After(timeout):
+			return results
+		}
+	}
+}
+
+func TestCheckHostHttps_HostnameMismatch(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"other.example.com"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != errHostname {
+		t.Fatalf("expected %q, got %+v", errHostname, results)
+	}
+	if !strings.HasPrefix(results[0].Host, "localhost:") {
+		t.Errorf("unexpected host %s", results[0].Host)
+	}
+	if results[0].Severity != SeverityWarning {
+		t.Errorf("hostname mismatch is not a warning %+v", results[0])
+	}
+}
+
+func TestCheckHostHttps_ExpirySeverity(t *testing.T) {
+	cases := []struct {
+		expiresIn time.Duration
+		want      Severity
+	}{
+		{24 * time.Hour, SeverityCritical},
+		{5 * 24 * time.Hour, SeverityWarning},
+	}
+	for _, c := range cases {
+		now := time.Now()
+		cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.Add(c.expiresIn))
+		addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+		_, port, _ := net.SplitHostPort(addr)
+
+		out := make(chan CheckResult, 10)
+		sc := NewSimpleCheck(nil, out)
+		sc.RootCAs = x509.NewCertPool()
+		sc.RootCAs.AddCert(cert.Leaf)
+		sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+		results := collectResults(out, 100*time.Millisecond)
+		if len(results) != 1 || results[0].Severity != c.want {
+			t.Errorf("expires in %s: expected %s, got %+v", c.expiresIn, c.want, results)
+		}
+	}
+}
+
+func TestSeverity_Text(t *testing.T) {
+	for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		text, err := s.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Severity
+		if err = got.UnmarshalText(text); err != nil || got != s {
+			t.Errorf("round trip %s: got %s, %v", s, got, err)
+		}
+	}
+	var s Severity
+	if err := s.UnmarshalText([]byte("fatal")); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
+
+func TestCheckHostHttps_Expired(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.AddDate(0, 0, -30), now.AddDate(0, 0, -3))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	NewSimpleCheck(nil, out).checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != errExpired {
+		t.Fatalf("expected %q, got %+v", errExpired, results)
+	}
+	if results[0].DaysRemaining != -3 || !results[0].NotAfter.Equal(cert.Leaf.NotAfter) {
+		t.Errorf("unexpected expiry fields %+v", results[0])
+	}
+	if results[0].Issuer != "CN=localhost" || results[0].Subject != "CN=localhost" || results[0].SerialNumber != cert.Leaf.SerialNumber.Text(16) {
+		t.Errorf("unexpected issuer fields %+v", results[0])
+	}
+	if results[0].Severity != SeverityCritical {
+		t.Errorf("expired result is not critical %+v", results[0])
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	cases := map[string]target{
+		"example.com":                    {addr: "example.com:443", serverName: "example.com"},
+		"example.com:8443":               {addr: "example.com:8443", serverName: "example.com"},
+		"10.0.0.1:8443@api.example.com":  {addr: "10.0.0.1:8443", serverName: "api.example.com"},
+		"*.example.com":                  {addr: "probe.example.com:443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"*.example.com:8443":             {addr: "probe.example.com:8443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"10.0.0.1@*.example.com":         {addr: "10.0.0.1:443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"*":                              {},
+		"[2001:db8::1]:8443":             {addr: "[2001:db8::1]:8443", serverName: "2001:db8::1"},
+		"2001:db8::1":                    {addr: "[2001:db8::1]:443", serverName: "2001:db8::1"},
+		"[2001:db8::1]":                  {addr: "[2001:db8::1]:443", serverName: "2001:db8::1"},
+		"smtp://mail.example.com:2525":   {addr: "mail.example.com:2525", serverName: "mail.example.com", scheme: smtpScheme},
+		"mail.example.com:587":           {addr: "mail.example.com:587", serverName: "mail.example.com", scheme: smtpScheme},
+		"IMAP://mail.example.com":        {addr: "mail.example.com:143", serverName: "mail.example.com", scheme: imapScheme},
+		"imaps://mail.example.com":       {addr: "mail.example.com:993", serverName: "mail.example.com", scheme: imapsScheme},
+		"pop3s://mail.example.com:1995":  {addr: "mail.example.com:1995", serverName: "mail.example.com", scheme: pop3sScheme},
+		"ftp://files.example.com":        {addr: "files.example.com:21", serverName: "files.example.com", scheme: ftpScheme},
+		"files.example.com:21":           {addr: "files.example.com:21", serverName: "files.example.com", scheme: ftpScheme},
+		"api.example.com/10.0.0.1:8443":  {addr: "10.0.0.1:8443", serverName: "api.example.com"},
+		"api.example.com/10.0.0.1":       {addr: "10.0.0.1:443", serverName: "api.example.com"},
+		"api.example.com/[2001:db8::1]":  {addr: "[2001:db8::1]:443", serverName: "api.example.com"},
+		"*.example.com/10.0.0.1":         {addr: "10.0.0.1:443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"smtp://mx.example.com/10.0.0.2": {addr: "10.0.0.2:25", serverName: "mx.example.com", scheme: smtpScheme},
+	}
+	for host, want := range cases {
+		if got := parseTarget(host, "probe", false); got != want {
+			t.Errorf("parseTarget(%q) = %+v, want %+v", host, got, want)
+		}
+	}
+}
+
+func TestParseTarget_WildcardApex(t *testing.T) {
+	cases := map[string]target{
+		"*.example.com":          {addr: "example.com:443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"*.example.com:8443":     {addr: "example.com:8443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"*.example.com/10.0.0.1": {addr: "10.0.0.1:443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"10.0.0.1@*.example.com": {addr: "10.0.0.1:443", serverName: "probe.example.com", wildcard: "*.example.com"},
+		"www.example.com":        {addr: "www.example.com:443", serverName: "www.example.com"},
+	}
+	for host, want := range cases {
+		if got := parseTarget(host, "probe", true); got != want {
+			t.Errorf("parseTarget(%q) = %+v, want %+v", host, got, want)
+		}
+	}
+	if s := parseTarget("*.example.com:8443", "probe", true).String(); s != "*.example.com:8443" {
+		t.Errorf("expected the wildcard in results, got %s", s)
+	}
+	// 不设置WildcardApex时结果中同样不出现随机的label
+	for host, want := range map[string]string{
+		"*.example.com:8443":     "*.example.com:8443",
+		"*.example.com/10.0.0.1": "10.0.0.1:443@*.example.com",
+	} {
+		if s := parseTarget(host, newWildcardLabel(), false).String(); s != want {
+			t.Errorf("parseTarget(%q).String() = %s, want %s", host, s, want)
+		}
+	}
+}
+
+func TestCheckHostHttps_WildcardApex(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"*.localhost"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	sni := make(chan string, 1)
+	addr := startTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni <- hello.ServerName
+			return nil, nil
+		},
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.WildcardLabel = "probe"
+	sc.WildcardApex = true
+	sc.checkHostHttps(context.Background(), "*.localhost:"+port, 10)
+	if name := <-sni; name != "probe.localhost" {
+		t.Errorf("server got SNI %q", name)
+	}
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" || results[0].Host != "*.localhost:"+port {
+		t.Fatalf("wildcard certificate did not validate, got %+v", results)
+	}
+}
+
+func TestCheckHostHttps_StrictMode(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, port, _ := net.SplitHostPort(addr)
+	host := "localhost:" + port
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.Retry = 1
+	sc.checkHostHttps(context.Background(), host, 10)
+	if results := collectResults(out, 100*time.Millisecond); len(results) != 0 || sc.Failures() != 1 {
+		t.Fatalf("expected the self-signed host to be skipped, got %+v", results)
+	}
+	sc.StrictMode = true
+	sc.checkHostHttps(context.Background(), host, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 2 || results[0].WarnMsg != errUntrusted || results[1].WarnMsg != "expires in 5 days" || sc.Failures() != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestCheckHostHttps_Endpoints(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"api.example.com"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.Retry = 1
+	sc.Endpoints = map[string]string{"api.example.com": addr}
+	sc.checkHostHttps(context.Background(), "api.example.com", 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" || results[0].Host != "api.example.com:443" {
+		t.Fatalf("endpoint override not used, got %+v", results)
+	}
+	// 指定了连接地址的host不使用Endpoints
+	if got := sc.endpoint(parseTarget("api.example.com/10.0.0.1", "", false)); got.addr != "10.0.0.1:443" {
+		t.Errorf("unexpected endpoint %+v", got)
+	}
+	sc.Endpoints = map[string]string{"api.example.com": "10.0.0.5"}
+	if got := sc.endpoint(parseTarget("api.example.com:8443", "", false)); got.addr != "10.0.0.5:8443" {
+		t.Errorf("expected the host's port to be kept, got %+v", got)
+	}
+}
+
+func TestCheckHostHttps_Wildcard(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"*.example.com"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	sni := make(chan string, 1)
+	addr := startTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni <- hello.ServerName
+			return nil, nil
+		},
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.checkHostHttps(context.Background(), "127.0.0.1:"+port+"@*.example.com", 10)
+	if name := <-sni; name != defaultWildcardLabel+".example.com" || !strings.HasPrefix(name, "check-certs-") {
+		t.Errorf("server got SNI %q", name)
+	}
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" {
+		t.Fatalf("wildcard certificate did not validate, got %+v", results)
+	}
+}
+
+func TestCheckHostHttps_SNI(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"api.example.com"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	sni := make(chan string, 1)
+	addr := startTLSServer(t, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			sni <- hello.ServerName
+			return &cert, nil
+		},
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	NewSimpleCheck(nil, out).checkHostHttps(context.Background(), "localhost:"+port+"@api.example.com", 10)
+	select {
+	case name := <-sni:
+		if name != "api.example.com" {
+			t.Errorf("server got SNI %q", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not receive a handshake")
+	}
+}
+
+func TestCheckHostHttps_RootCAs(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" || results[0].DaysRemaining != 5 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestCheckHostHttps_NotYetValid(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(5*time.Hour+time.Minute), now.AddDate(1, 0, 0))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	NewSimpleCheck(nil, out).checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "certificate not yet valid, valid in 5 hours" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestSimpleCheck_CheckReturnsWhenInputClosed(t *testing.T) {
+	in := make(chan Host, 3)
+	in <- Host{Host: ""}
+	in <- Host{Host: "@"}
+	in <- Host{Host: ""}
+	close(in)
+	done := make(chan struct{})
+	go func() {
+		NewSimpleCheck(in, make(chan CheckResult)).Check(context.Background(), 10)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Check did not return after input was closed")
+	}
+}
+
+// TestSimpleCheck_CheckNoLeakAcrossCycles 每轮检查都使用同一个SimpleCheck，
+// 无论输入关闭还是ctx取消结束一轮，Check返回后都不应留下读取输入的goroutine
+func TestSimpleCheck_CheckNoLeakAcrossCycles(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(0, 0, 5))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 100)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	before := runtime.NumGoroutine()
+	for cycle := 0; cycle < 10; cycle++ {
+		in := make(chan Host, 3)
+		for i := 0; i < 3; i++ {
+			in <- Host{Host: "localhost:" + port}
+		}
+		sc.in = in
+		ctx, cancel := context.WithCancel(context.Background())
+		if cycle%2 == 0 {
+			close(in)
+		} else {
+			// 输入不关闭，由ctx取消结束本轮
+			time.AfterFunc(50*time.Millisecond, cancel)
+		}
+		sc.Check(ctx, 10)
+		cancel()
+		collectResults(out, 10*time.Millisecond)
+	}
+	// 等待服务端处理连接的goroutine退出
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines grew from %d to %d after 10 cycles", before, after)
+	}
+}
+
+func TestCheckHostHttps_Failures(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	tlsAddr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := ln.Addr().String()
+	ln.Close()
+
+	cases := map[string]string{
+		"localhost:" + tlsPort: "TLS handshake failed: ",
+		closedAddr:             errConnRefused,
+	}
+	for host, want := range cases {
+		out := make(chan CheckResult, 10)
+		sc := NewSimpleCheck(nil, out)
+		sc.Retry = 1
+		sc.checkHostHttps(context.Background(), host, 10)
+		if results := collectResults(out, 100*time.Millisecond); len(results) != 0 {
+			t.Errorf("%s: expected no results without AlertOnFailure, got %+v", host, results)
+		}
+		sc.AlertOnFailure = true
+		sc.checkHostHttps(context.Background(), host, 10)
+		results := collectResults(out, 100*time.Millisecond)
+		if len(results) != 1 || !strings.HasPrefix(results[0].WarnMsg, want) {
+			t.Errorf("%s: expected %q, got %+v", host, want, results)
+		}
+		// 无论是否告警都计入失败数
+		if sc.Failures() != 2 {
+			t.Errorf("%s: expected 2 failures, got %d", host, sc.Failures())
+		}
+	}
+}
+
+func TestFailureResult_DNS(t *testing.T) {
+	err := &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nx.invalid"}}
+	if res := failureResult("nx.invalid:443", err); res.WarnMsg != "DNS resolution failed: no such host" {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestCheckHostHttps_RetryTransientFailure(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = 10 * time.Millisecond
+	t.Cleanup(func() { retryBackoff = backoff })
+
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(0, 0, 5).Add(time.Hour))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	// 第一次连接直接关闭，模拟网络抖动
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				conn.Close()
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				_ = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = pool
+	sc.AlertOnFailure = true
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestCheckHostHttps_HandshakeTimeout(t *testing.T) {
+	// 只接受连接，从不响应TLS握手
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		ln.Close()
+		close(done)
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-done
+				conn.Close()
+			}()
+		}
+	}()
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.Retry = 1
+	sc.Timeout = 100 * time.Millisecond
+	sc.AlertOnFailure = true
+	start := time.Now()
+	sc.checkHostHttps(context.Background(), ln.Addr().String(), 10)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("check took %s, expected to be abandoned after the timeout", elapsed)
+	}
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || !strings.HasPrefix(results[0].WarnMsg, "TLS handshake failed: ") {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestSimpleCheck_WeakKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		key    any
+		minRSA int
+		want   string
+	}{
+		{&rsaKey.PublicKey, 0, "RSA 1024 bits"},
+		{&rsaKey.PublicKey, 1024, ""},
+		{&p224.PublicKey, 0, "ECDSA P-224"},
+		{&p256.PublicKey, 0, ""},
+	}
+	for _, c := range cases {
+		sc := &SimpleCheck{MinRSABits: c.minRSA}
+		if got := sc.weakKey(&x509.Certificate{PublicKey: c.key}); got != c.want {
+			t.Errorf("weakKey(%T, min %d) = %q, want %q", c.key, c.minRSA, got, c.want)
+		}
+	}
+}
+
+func TestCheckHostHttps_ClientCert(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	clientCert := newTestCert(t, []string{"client"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+	addr := startTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		// TLS 1.3中服务端在客户端握手完成后才拒绝客户端证书，限制为1.2让客户端握手直接失败
+		MaxVersion: tls.VersionTLS12,
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	// 不出示客户端证书时握手失败
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.AlertOnFailure = true
+	sc.Retry = 1
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || !strings.HasPrefix(results[0].WarnMsg, "TLS handshake failed") {
+		t.Fatalf("expected handshake failure, got %+v", results)
+	}
+
+	sc.ClientCerts = []tls.Certificate{clientCert}
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results = collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+// newTestChain 生成根证书、中间证书和由中间证书签发的叶子证书
+func newTestChain(t *testing.T, dnsName string) (root, intermediate *x509.Certificate, leaf tls.Certificate) {
+	t.Helper()
+	now := time.Now()
+	issue := func(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		template.SerialNumber = big.NewInt(now.UnixNano())
+		template.NotBefore, template.NotAfter = now.Add(-time.Hour), now.AddDate(1, 0, 0)
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	ca := func(cn string) *x509.Certificate {
+		return &x509.Certificate{Subject: pkix.Name{CommonName: cn}, KeyUsage: x509.KeyUsageCertSign, BasicConstraintsValid: true, IsCA: true}
+	}
+	root, rootKey := issue(ca("root"), nil, nil)
+	intermediate, intermediateKey := issue(ca("intermediate"), root, rootKey)
+	leafCert, leafKey := issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: dnsName},
+		DNSNames:    []string{dnsName},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, intermediate, intermediateKey)
+	return root, intermediate, tls.Certificate{Certificate: [][]byte{leafCert.Raw}, PrivateKey: leafKey, Leaf: leafCert}
+}
+
+func TestCheckHostHttps_MissingIntermediates(t *testing.T) {
+	root, intermediate, leaf := newTestChain(t, "localhost")
+	full := leaf
+	full.Certificate = [][]byte{leaf.Leaf.Raw, intermediate.Raw}
+	for _, tc := range []struct {
+		name string
+		cert tls.Certificate
+		want []string
+	}{
+		{"leaf only", leaf, []string{fmt.Sprintf(errIncompleteChain, 1)}},
+		{"full chain", full, []string{}},
+	} {
+		addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{tc.cert}})
+		_, port, _ := net.SplitHostPort(addr)
+		out := make(chan CheckResult, 10)
+		sc := NewSimpleCheck(nil, out)
+		sc.RootCAs = x509.NewCertPool()
+		sc.RootCAs.AddCert(root)
+		sc.CheckChain = true
+		sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+		results := collectResults(out, 100*time.Millisecond)
+		if len(results) != len(tc.want) {
+			t.Fatalf("%s: unexpected results %+v", tc.name, results)
+		}
+		for i := range tc.want {
+			if results[i].WarnMsg != tc.want[i] || results[i].Issuer != "CN=intermediate" {
+				t.Errorf("%s: unexpected result %+v", tc.name, results[i])
+			}
+		}
+	}
+}
+
+func TestServedChainComplete(t *testing.T) {
+	root, intermediate, leaf := newTestChain(t, "localhost")
+	chains := [][]*x509.Certificate{{leaf.Leaf, intermediate, root}}
+	if servedChainComplete([]*x509.Certificate{leaf.Leaf}, chains) {
+		t.Error("chain without intermediate reported complete")
+	}
+	if !servedChainComplete([]*x509.Certificate{leaf.Leaf, intermediate}, chains) {
+		t.Error("full chain reported incomplete")
+	}
+	if !servedChainComplete([]*x509.Certificate{root}, [][]*x509.Certificate{{root}}) {
+		t.Error("self-signed certificate reported incomplete")
+	}
+}
+
+func TestHSTSMaxAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"max-age=31536000; includeSubDomains": 365 * 24 * time.Hour,
+		`includeSubDomains; Max-Age="600"`:    10 * time.Minute,
+	}
+	for header, want := range cases {
+		if got, ok := hstsMaxAge(header); !ok || got != want {
+			t.Errorf("hstsMaxAge(%q) = %v, %v, want %v", header, got, ok, want)
+		}
+	}
+	if _, ok := hstsMaxAge("includeSubDomains"); ok {
+		t.Error("expected no max-age")
+	}
+}
+
+func TestCheckHostHttps_HSTS(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	hsts := make(chan string, 1)
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := <-hsts; header != "" {
+			w.Header().Set("Strict-Transport-Security", header)
+		}
+	}))
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	if results := collectResults(out, 100*time.Millisecond); len(results) != 0 {
+		t.Fatalf("expected no results without CheckHSTS, got %+v", results)
+	}
+	sc.CheckHSTS = true
+	cases := map[string][]string{
+		"":                                    {fmt.Sprintf(errHSTS, "missing")},
+		"max-age=600":                         {fmt.Sprintf(errHSTS, "max-age=600")},
+		"max-age=31536000; includeSubDomains": {},
+	}
+	for header, want := range cases {
+		hsts <- header
+		sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+		results := collectResults(out, 100*time.Millisecond)
+		if len(results) != len(want) || (len(want) == 1 && results[0].WarnMsg != want[0]) {
+			t.Errorf("%q: unexpected results %+v", header, results)
+		}
+	}
+}
+
+func TestCheckHostHttps_TLSVersion(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || !strings.HasPrefix(results[0].WarnMsg, "negotiated TLS 1.1 with ") || !strings.HasSuffix(results[0].WarnMsg, "below the minimum TLS 1.2") {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	sc.MinTLSVersion = tls.VersionTLS11
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	if results := collectResults(out, 100*time.Millisecond); len(results) != 0 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("expected unsupported version error")
+	}
+	sc.HandshakeMinVersion = tls.VersionTLS12
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results = collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || results[0].WarnMsg != "server doesn't support TLS 1.2 or later, refused to connect" || results[0].Severity != SeverityCritical {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestCheckHostHttps_OCSPStapling(t *testing.T) {
+	now := time.Now()
+	issuer := newTestCert(t, []string{"issuer"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, issuer.Leaf, &key.PublicKey, issuer.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staple := func(status int, nextUpdate time.Time) []byte {
+		resp, err := ocsp.CreateResponse(issuer.Leaf, issuer.Leaf, ocsp.Response{
+			Status:       status,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   now.Add(-time.Hour),
+			NextUpdate:   nextUpdate,
+			RevokedAt:    now.Add(-time.Hour),
+		}, issuer.PrivateKey.(*ecdsa.PrivateKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	cases := []struct {
+		name   string
+		staple []byte
+		want   string
+	}{
+		{"good", staple(ocsp.Good, now.AddDate(0, 0, 3)), ""},
+		{"none", nil, ""},
+		{"revoked", staple(ocsp.Revoked, now.AddDate(0, 0, 3)), errRevoked},
+		{"expired", staple(ocsp.Good, now.Add(-time.Minute)), errStapleExpired},
+		{"expiring", staple(ocsp.Good, now.Add(5*time.Hour+time.Minute)), "stapled OCSP response expires in 5 hours"},
+	}
+	for _, c := range cases {
+		cert := tls.Certificate{Certificate: [][]byte{der, issuer.Certificate[0]}, PrivateKey: key, OCSPStaple: c.staple}
+		addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+		_, port, _ := net.SplitHostPort(addr)
+
+		out := make(chan CheckResult, 10)
+		sc := NewSimpleCheck(nil, out)
+		sc.CheckOCSPStapling = true
+		sc.RootCAs = x509.NewCertPool()
+		sc.RootCAs.AddCert(issuer.Leaf)
+		sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+		results := collectResults(out, 100*time.Millisecond)
+		if c.want == "" {
+			if len(results) != 0 {
+				t.Errorf("%s: unexpected results %+v", c.name, results)
+			}
+			continue
+		}
+		if len(results) != 1 || results[0].WarnMsg != c.want {
+			t.Errorf("%s: expected %q, got %+v", c.name, c.want, results)
+		}
+	}
+}
+
+func TestCheckHostHttps_ReportChecked(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(cert.Leaf)
+	sc.ReportChecked = true
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 2 || !IsChecked(results[0]) || results[1].WarnMsg != "expires in 5 days" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[0].SerialNumber != cert.Leaf.SerialNumber.Text(16) || results[0].DaysRemaining != 5 || results[0].Severity != SeverityInfo {
+		t.Errorf("unexpected certificate info %+v", results[0])
+	}
+}
+
+func TestSimpleCheck_GraceDays(t *testing.T) {
+	now := time.Now()
+	leaf := newTestCert(t, []string{"a.com"}, now.Add(-time.Hour), now.Add(12*24*time.Hour+time.Hour)).Leaf
+	expiring := func(sc *SimpleCheck) bool {
+		out := make(chan CheckResult, 10)
+		sc.out = out
+		sc.checkChain("a.com:443", []*x509.Certificate{leaf}, false, 10, now)
+		close(out)
+		for res := range out {
+			if res.WarnMsg == "expires in 12 days" {
+				return true
+			}
+		}
+		return false
+	}
+	state, _ := NewAlertState("")
+	if expiring(&SimpleCheck{AlertState: state, GraceDays: 3}) {
+		t.Error("healthy host warned")
+	}
+	state.Notify(CheckResult{Host: "a.com:443", WarnMsg: "expires in 10 days"})
+	if _, err := state.EndCycle(); err != nil {
+		t.Fatal(err)
+	}
+	// 上一轮已告警，12天仍在warnDays+graceDays内
+	if !expiring(&SimpleCheck{AlertState: state, GraceDays: 3}) {
+		t.Error("alerted host was cleared within the grace period")
+	}
+	if expiring(&SimpleCheck{AlertState: state, GraceDays: 1}) {
+		t.Error("alerted host was not cleared after the grace period")
+	}
+}
+
+func TestSimpleCheck_SunsetCheck(t *testing.T) {
+	now := time.Now()
+	leaf := newTestCert(t, []string{"a.com"}, now.Add(-time.Hour), now.Add(40*24*time.Hour)).Leaf
+	intermediate := newTestCert(t, []string{"ca.a.com"}, now.Add(-time.Hour), now.Add(400*24*time.Hour)).Leaf
+	algs, err := ParseSunsetAlgorithms(map[string]string{"ecdsa-sha256": "2020-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sunsets := func(sc *SimpleCheck) int {
+		out := make(chan CheckResult, 10)
+		sc.out = out
+		sc.checkChain("a.com:443", []*x509.Certificate{leaf, intermediate}, false, 10, now)
+		close(out)
+		count := 0
+		for res := range out {
+			if res.WarnMsg == fmt.Sprintf(errSunsetAlg, "ECDSA-SHA256") {
+				count++
+			}
+		}
+		return count
+	}
+	if n := sunsets(&SimpleCheck{}); n != 0 {
+		t.Errorf("built-in table flagged ECDSA-SHA256 %d times", n)
+	}
+	if n := sunsets(&SimpleCheck{SunsetAlgs: algs}); n != 2 {
+		t.Errorf("expected both certificates flagged, got %d", n)
+	}
+	if n := sunsets(&SimpleCheck{SunsetAlgs: algs, SunsetCheck: sunsetLeaf}); n != 1 {
+		t.Errorf("expected only the leaf flagged, got %d", n)
+	}
+	if n := sunsets(&SimpleCheck{SunsetAlgs: algs, SunsetCheck: sunsetOff}); n != 0 {
+		t.Errorf("expected no sunset warnings, got %d", n)
+	}
+	if _, err = ParseSunsetAlgorithms(map[string]string{"SHA3-RSA": "2020-01-01"}); err == nil {
+		t.Error("expected unknown algorithm error")
+	}
+}
+
+func TestSimpleCheck_Thresholds(t *testing.T) {
+	now := time.Now()
+	thresholds := []Threshold{{Days: 30, Severity: SeverityInfo}, {Days: 7, Severity: SeverityWarning}, {Hours: 72, Severity: SeverityCritical}}
+	cases := []struct {
+		remaining time.Duration
+		warnMsg   string
+		severity  Severity
+	}{
+		{40 * 24 * time.Hour, "", 0},
+		{20*24*time.Hour + time.Hour, "expires in 20 days", SeverityInfo},
+		{5*24*time.Hour + time.Hour, "expires in 5 days", SeverityWarning},
+		{72*time.Hour + 30*time.Minute, "expires in 72 hours", SeverityCritical},
+	}
+	for _, c := range cases {
+		leaf := newTestCert(t, []string{"a.com"}, now.Add(-time.Hour), now.Add(c.remaining)).Leaf
+		out := make(chan CheckResult, 10)
+		sc := &SimpleCheck{out: out, Thresholds: thresholds}
+		sc.checkChain("a.com:443", []*x509.Certificate{leaf}, false, 10, now)
+		close(out)
+		var got CheckResult
+		for res := range out {
+			got = res
+		}
+		if got.WarnMsg != c.warnMsg || got.Severity != c.severity {
+			t.Errorf("remaining %s: expected %q %s, got %q %s", c.remaining, c.warnMsg, c.severity, got.WarnMsg, got.Severity)
+		}
+	}
+}
+
+func TestSimpleCheck_DefaultThresholds(t *testing.T) {
+	cases := []struct {
+		warnDays  int
+		remaining time.Duration
+		found     bool
+		severity  Severity
+	}{
+		{10, 5 * 24 * time.Hour, true, SeverityWarning},
+		{10, 30 * time.Hour, true, SeverityCritical},
+		// critical一级超过warnDays时不使用
+		{0, 30 * time.Hour, false, 0},
+		{1, 30 * time.Hour, false, 0},
+		{1, 20 * time.Hour, true, SeverityWarning},
+		{2, 48*time.Hour + 30*time.Minute, false, 0},
+	}
+	sc := &SimpleCheck{}
+	for _, c := range cases {
+		got, found := sc.expiryThreshold(c.remaining, c.warnDays, 0)
+		if found != c.found || (found && got.Severity != c.severity) {
+			t.Errorf("warnDays %d, remaining %s: expected %v %s, got %v %s", c.warnDays, c.remaining, c.found, c.severity, found, got.Severity)
+		}
+	}
+}
+
+func TestSimpleCheck_PruneMetrics(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	paths := make([]string, 0, 2)
+	for _, name := range []string{"a.der", "b.der"} {
+		cert := newTestCert(t, []string{name}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, cert.Certificate[0], 0o600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	// provider返回的host没有端口，指标的host标签为localhost:443
+	tlsHost := "localhost:443"
+	cycle := func(hosts ...string) {
+		in := make(chan Host, len(hosts))
+		for _, host := range hosts {
+			in <- Host{Host: host}
+		}
+		close(in)
+		sc := NewSimpleCheck(in, make(chan CheckResult, 10))
+		sc.RootCAs = x509.NewCertPool()
+		sc.RootCAs.AddCert(cert.Leaf)
+		sc.Endpoints = map[string]string{"localhost": addr}
+		sc.Check(context.Background(), 30)
+		sc.PruneMetrics()
+	}
+	cycle("file://"+paths[0], "file://"+paths[1], "localhost")
+	if testutil.ToFloat64(certExpiryDays.WithLabelValues(tlsHost)) <= 0 {
+		t.Fatalf("expected expiry metric of %s", tlsHost)
+	}
+	// 第二轮只检查a.der，b.der和TLS host的指标被删除
+	cycle("file://" + paths[0])
+	if certExpiryDays.DeleteLabelValues(paths[1]) || certExpiryDays.DeleteLabelValues(tlsHost) {
+		t.Error("expected expiry metrics of the removed hosts to be deleted")
+	}
+	if n := tlsNegotiated.DeletePartialMatch(prometheus.Labels{"host": tlsHost}); n != 0 {
+		t.Errorf("expected negotiated metrics of %s to be deleted, got %d", tlsHost, n)
+	}
+	if !certExpiryDays.DeleteLabelValues(paths[0]) {
+		t.Error("expected metrics of the checked host to be kept")
+	}
+}
@@ -1,50 +1,593 @@
 package pkg
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
-	"log"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 )
 
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// wildcardLabelPattern 单个DNS label，字母数字开头和结尾，中间可以有-，最长63个字符
+var wildcardLabelPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// expandEnv 把value中的${ENV_VAR}替换为环境变量的值，引用的环境变量未设置时返回错误
+func expandEnv(key, value string) (string, error) {
+	var missing string
+	expanded := envPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envPattern.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("config key %s references unset environment variable %s", key, missing)
+	}
+	return expanded, nil
+}
+
+func mustExpandEnv(name, key, value string) string {
+	expanded, err := expandEnv(key, value)
+	if err != nil {
+		fatal("expand config value failed", "name", name, "error", err)
+	}
+	return expanded
+}
+
 type ProviderConfig struct {
-	Name         string         `yaml:"name"`
-	ProviderType string         `yaml:"provider"`
-	Addition     map[string]any `yaml:"config"`
-	Domains      []string       `yaml:"domains"`
+	Name         string         `yaml:"name" json:"name" toml:"name"`
+	ProviderType string         `yaml:"provider" json:"provider" toml:"provider"`
+	Addition     map[string]any `yaml:"config" json:"config" toml:"config"`
+	Domains      []string       `yaml:"domains" json:"domains" toml:"domains"`
+	WarnDays     int            `yaml:"warnDays" json:"warnDays" toml:"warnDays"` // 覆盖全局的warnDays，为0时使用全局配置
+	line         int            // 在配置文件中的行号，用于校验时提示
+}
+
+func (pc *ProviderConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain ProviderConfig
+	if err := node.Decode((*plain)(pc)); err != nil {
+		return err
+	}
+	pc.line = node.Line
+	return nil
 }
 
 func (pc *ProviderConfig) Get(key string) string {
 	if pc.Addition[key] == nil {
-		log.Fatalln(key, "not exist")
+		fatal("provider config key not exist", "provider", pc.Name, "key", key)
 	}
-	return pc.Addition[key].(string)
+	return mustExpandEnv(pc.Name, key, pc.Addition[key].(string))
+}
+
+// GetDefault 用于可选配置项，不存在时返回value，数字等非字符串的值转为字符串
+func (pc *ProviderConfig) GetDefault(key, value string) string {
+	if v, ok := pc.Addition[key]; ok && v != nil {
+		return mustExpandEnv(pc.Name, key, fmt.Sprint(v))
+	}
+	return value
+}
+
+// GetMap 用于嵌套的可选配置项，例如查询参数，不存在时返回空map
+func (pc *ProviderConfig) GetMap(key string) map[string]string {
+	values := make(map[string]string)
+	m, _ := pc.Addition[key].(map[string]any)
+	for k, v := range m {
+		values[k] = mustExpandEnv(pc.Name, key+"."+k, fmt.Sprint(v))
+	}
+	return values
 }
 
 type NotifyConfig struct {
-	Type   string         `yaml:"type"`
-	Config map[string]any `yaml:"config"`
+	Type   string         `yaml:"type" json:"type" toml:"type"`
+	Config map[string]any `yaml:"config" json:"config" toml:"config"`
+	line   int
+}
+
+func (nc *NotifyConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain NotifyConfig
+	if err := node.Decode((*plain)(nc)); err != nil {
+		return err
+	}
+	nc.line = node.Line
+	return nil
 }
 
 func (nc *NotifyConfig) Get(key string) string {
-	return nc.Config[key].(string)
+	return mustExpandEnv(nc.Type, key, nc.Config[key].(string))
+}
+
+// GetDefault 用于可选配置项，不存在时返回value，数字等非字符串的值转为字符串
+func (nc *NotifyConfig) GetDefault(key, value string) string {
+	if v, ok := nc.Config[key]; ok && v != nil {
+		return mustExpandEnv(nc.Type, key, fmt.Sprint(v))
+	}
+	return value
 }
 
+// GetMap 用于嵌套的可选配置项，例如自定义请求头，不存在时返回空map
+func (nc *NotifyConfig) GetMap(key string) map[string]string {
+	values := make(map[string]string)
+	m, _ := nc.Config[key].(map[string]any)
+	for k, v := range m {
+		values[k] = mustExpandEnv(nc.Type, key+"."+k, fmt.Sprint(v))
+	}
+	return values
+}
+
+// NewConfig 读取配置，失败时退出
 func NewConfig(path string) *Config {
+	config, err := LoadConfig(path)
+	if err != nil {
+		fatal("load config failed", "path", path, "error", err)
+	}
+	return config
+}
+
+// LoadConfig 根据扩展名选择解析格式，支持.yaml/.yml、.json、.toml，其他扩展名按YAML解析
+func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
+	return parseConfig(filepath.Ext(path), data)
+}
+
+func parseConfig(ext string, data []byte) (*Config, error) {
 	var config Config
-	err = yaml.Unmarshal(data, &config)
+	var err error
+	switch strings.ToLower(ext) {
+	case ".json":
+		err = json.Unmarshal(data, &config)
+	case ".toml":
+		err = toml.Unmarshal(data, &config)
+	default:
+		err = yaml.Unmarshal(data, &config)
+	}
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
-	return &config
+	return &config, nil
 }
 
 type Config struct {
-	Timeout   int               `yaml:"timeout"`
-	WarnDays  int               `yaml:"warnDays"`
-	Providers []*ProviderConfig `yaml:"providers"`
-	Notifies  []*NotifyConfig   `yaml:"notifies"`
+	Timeout           int    `yaml:"timeout" json:"timeout" toml:"timeout"`
+	WarnDays          int    `yaml:"warnDays" json:"warnDays" toml:"warnDays"`
+	CheckInterval     string `yaml:"checkInterval" json:"checkInterval" toml:"checkInterval"` // Go的duration格式，例如12h
+	CheckOCSP         bool   `yaml:"checkOCSP" json:"checkOCSP" toml:"checkOCSP"`
+	CheckOCSPStapling bool   `yaml:"checkOCSPStapling" json:"checkOCSPStapling" toml:"checkOCSPStapling"`
+	CheckChain        bool   `yaml:"checkChain" json:"checkChain" toml:"checkChain"`
+	CheckHSTS         bool   `yaml:"checkHSTS" json:"checkHSTS" toml:"checkHSTS"`
+	MinHSTSMaxAge     int    `yaml:"minHSTSMaxAge" json:"minHSTSMaxAge" toml:"minHSTSMaxAge"` // 单位为秒
+	MinTLSVersion     string `yaml:"minTLSVersion" json:"minTLSVersion" toml:"minTLSVersion"`
+	// 签名算法停用检查的范围：all、leaf或off，为空时为all
+	SunsetCheck      string            `yaml:"sunsetCheck" json:"sunsetCheck" toml:"sunsetCheck"`
+	SunsetAlgorithms map[string]string `yaml:"sunsetAlgorithms" json:"sunsetAlgorithms" toml:"sunsetAlgorithms"`
+	// 握手接受的最低版本，为空时接受TLS 1.0
+	HandshakeMinTLSVersion string             `yaml:"handshakeMinTLSVersion" json:"handshakeMinTLSVersion" toml:"handshakeMinTLSVersion"`
+	WildcardLabel          string             `yaml:"wildcardLabel" json:"wildcardLabel" toml:"wildcardLabel"`
+	WildcardApex           bool               `yaml:"wildcardApex" json:"wildcardApex" toml:"wildcardApex"`
+	Endpoints              map[string]string  `yaml:"endpoints" json:"endpoints" toml:"endpoints"` // hostname到拨号地址的映射
+	Thresholds             []Threshold        `yaml:"thresholds" json:"thresholds" toml:"thresholds"`
+	AlertOnFailure         bool               `yaml:"alertOnFailure" json:"alertOnFailure" toml:"alertOnFailure"`
+	StrictMode             bool               `yaml:"strictMode" json:"strictMode" toml:"strictMode"` // 仍然检查不受信任的证书
+	Summary                bool               `yaml:"summary" json:"summary" toml:"summary"`          // 每轮检查结束后发送一条汇总通知
+	FailOnEmptyProvider    bool               `yaml:"failOnEmptyProvider" json:"failOnEmptyProvider" toml:"failOnEmptyProvider"`
+	CheckRetry             int                `yaml:"checkRetry" json:"checkRetry" toml:"checkRetry"`
+	MinRSABits             int                `yaml:"minRSABits" json:"minRSABits" toml:"minRSABits"`
+	MinECDSABits           int                `yaml:"minECDSABits" json:"minECDSABits" toml:"minECDSABits"`
+	CAFile                 string             `yaml:"caFile" json:"caFile" toml:"caFile"`
+	ClientCert             string             `yaml:"clientCert" json:"clientCert" toml:"clientCert"`
+	ClientKey              string             `yaml:"clientKey" json:"clientKey" toml:"clientKey"`
+	Concurrency            int                `yaml:"concurrency" json:"concurrency" toml:"concurrency"`
+	ProviderConcurrency    int                `yaml:"providerConcurrency" json:"providerConcurrency" toml:"providerConcurrency"` // 所有provider同时进行的接口请求数上限
+	Proxy                  string             `yaml:"proxy" json:"proxy" toml:"proxy"`
+	NoProxy                string             `yaml:"noProxy" json:"noProxy" toml:"noProxy"`
+	MetricsAddr            string             `yaml:"metricsAddr" json:"metricsAddr" toml:"metricsAddr"`
+	HealthAddr             string             `yaml:"healthAddr" json:"healthAddr" toml:"healthAddr"`
+	CheckToken             string             `yaml:"checkToken" json:"checkToken" toml:"checkToken"` // POST /check的Bearer token，可以使用${ENV_VAR}
+	LogLevel               string             `yaml:"logLevel" json:"logLevel" toml:"logLevel"`
+	LogFormat              string             `yaml:"logFormat" json:"logFormat" toml:"logFormat"`
+	Report                 *ReportConfig      `yaml:"report" json:"report" toml:"report"`
+	AlertState             *AlertStateConfig  `yaml:"alertState" json:"alertState" toml:"alertState"`
+	Fingerprints           *FingerprintConfig `yaml:"fingerprints" json:"fingerprints" toml:"fingerprints"`
+	Include                []string           `yaml:"include" json:"include" toml:"include"`
+	ProviderDefaults       ProviderDefaults   `yaml:"providerDefaults" json:"providerDefaults" toml:"providerDefaults"`
+	Exclude                []string           `yaml:"exclude" json:"exclude" toml:"exclude"`
+	Providers              []*ProviderConfig  `yaml:"providers" json:"providers" toml:"providers"`
+	Notifies               []*NotifyConfig    `yaml:"notifies" json:"notifies" toml:"notifies"`
+}
+
+// ReportConfig 每轮检查结束后把全部结果写入JSON或CSV文件
+type ReportConfig struct {
+	Path   string `yaml:"path" json:"path" toml:"path"`
+	Format string `yaml:"format" json:"format" toml:"format"` // json或csv，为空时按path的扩展名判断
+	Rotate bool   `yaml:"rotate" json:"rotate" toml:"rotate"` // 为true时每轮写入带时间的新文件，否则覆盖
+}
+
+// ProviderDefaults 所有provider共用的请求参数，为0时使用maxRetry和defaultSize
+type ProviderDefaults struct {
+	MaxRetry int `yaml:"maxRetry" json:"maxRetry" toml:"maxRetry"` // 每个请求的最多尝试次数
+	PageSize int `yaml:"pageSize" json:"pageSize" toml:"pageSize"` // 分页接口每页的记录数
+	// 第一次重试前的等待时间，之后每次翻倍并加上随机抖动，为空时使用defaultRetryDelay
+	RetryDelay string `yaml:"retryDelay" json:"retryDelay" toml:"retryDelay"`
+	// 重试等待时间的上限，为空时使用defaultMaxRetryDelay
+	MaxRetryDelay string `yaml:"maxRetryDelay" json:"maxRetryDelay" toml:"maxRetryDelay"`
+}
+
+func (pd ProviderDefaults) retries() int {
+	if pd.MaxRetry > 0 {
+		return pd.MaxRetry
+	}
+	return maxRetry
+}
+
+func (pd ProviderDefaults) retryDelay() time.Duration {
+	if delay, err := time.ParseDuration(pd.RetryDelay); err == nil && delay > 0 {
+		return delay
+	}
+	return defaultRetryDelay
+}
+
+func (pd ProviderDefaults) maxRetryDelay() time.Duration {
+	if delay, err := time.ParseDuration(pd.MaxRetryDelay); err == nil && delay > 0 {
+		return delay
+	}
+	return defaultMaxRetryDelay
+}
+
+func (pd ProviderDefaults) pageSize() int64 {
+	if pd.PageSize > 0 {
+		return int64(pd.PageSize)
+	}
+	return defaultSize
+}
+
+// AlertStateConfig 配置后同一个host的同一种告警只通知一次，host恢复后发送恢复通知
+type AlertStateConfig struct {
+	Path string `yaml:"path" json:"path" toml:"path"` // 保存状态的文件，为空时只保存在内存中，重启后会重新通知
+	// 已经因即将过期告警的host，剩余天数超过warnDays+graceDays后才解除告警，为0时不使用
+	GraceDays int `yaml:"graceDays" json:"graceDays" toml:"graceDays"`
+}
+
+// FingerprintConfig 配置后记录每个host的证书指纹，发现计划外的证书更换
+type FingerprintConfig struct {
+	Path          string `yaml:"path" json:"path" toml:"path"`                            // 保存指纹的文件，为空时只保存在内存中
+	AlertOnChange bool   `yaml:"alertOnChange" json:"alertOnChange" toml:"alertOnChange"` // 为false时只记录日志
+}
+
+// defaultCheckTimeout 未配置timeout时单个host的检查超时时间
+const defaultCheckTimeout = 10 * time.Second
+
+// CheckTimeout 单个host建立连接和完成TLS握手的超时时间，timeout单位为秒
+func (c *Config) CheckTimeout() time.Duration {
+	if c.Timeout > 0 {
+		return time.Duration(c.Timeout) * time.Second
+	}
+	return defaultCheckTimeout
+}
+
+// defaultCheckInterval 未配置checkInterval时两轮检查开始的间隔
+const defaultCheckInterval = 24 * time.Hour
+
+// Interval 两轮检查开始的间隔，checkInterval无效时使用默认值，Validate会报告无效的配置
+func (c *Config) Interval() time.Duration {
+	if interval, err := time.ParseDuration(c.CheckInterval); err == nil && interval > 0 {
+		return interval
+	}
+	return defaultCheckInterval
+}
+
+// CheckNowToken 展开环境变量后的checkToken，Validate已经检查过引用的环境变量
+func (c *Config) CheckNowToken() string {
+	token, _ := expandEnv("checkToken", c.CheckToken)
+	return token
+}
+
+// ProviderWarnDays provider单独设置了warnDays时使用它，否则使用全局配置
+func (c *Config) ProviderWarnDays(pc *ProviderConfig) int {
+	if pc.WarnDays > 0 {
+		return pc.WarnDays
+	}
+	return c.WarnDays
+}
+
+// requiredProviderKeys 各类型provider必须配置的config项
+var requiredProviderKeys = map[string][]string{
+	aliyun:       {"region", "domains"},
+	file:         {"filePath"},
+	west:         {"apiKey", "domains"},
+	route53:      {"accessKeyId", "secretAccessKey", "region", "hostedZoneIds"},
+	dnspod:       {"secretId", "secretKey", "domains"},
+	httpList:     {"url"},
+	k8s:          {},
+	rest:         {"url", "recordsPath"},
+	axfr:         {"server", "zones"},
+	certFile:     {"path"},
+	ns1:          {"apiKey", "zones"},
+	digitalOcean: {"token", "domains"},
+	linode:       {"token", "domains"},
+	huaweiDNS:    {"accessKey", "secretKey", "region", "zones"},
+	powerDNS:     {"apiUrl", "apiKey", "zones"},
+	gandi:        {"domains"},
+	vultr:        {"apiKey", "domains"},
+	hetzner:      {"apiToken", "zones"},
+	jdcloud:      {"accessKey", "secretKey", "domains"},
+}
+
+// providerValidators 必填项之外，各类型provider配置项之间的约束
+var providerValidators = map[string]func(values map[string]any) []string{
+	aliyun: validateAliyunCredentials,
+	gandi:  validateGandiCredentials,
+}
+
+// validateProviderOptions 检查各类型provider共用的可选项，构造provider时读取的都是已校验的值
+func validateProviderOptions(values map[string]any) []string {
+	problems := make([]string, 0)
+	if v, ok := optionalKey(values, "qps"); ok {
+		if _, err := parseQPS(v); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if v, ok := optionalKey(values, "concurrency"); ok {
+		if _, err := parseConcurrency(v); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// optionalKey 返回已配置的可选项展开环境变量后的值，未配置或引用的环境变量未设置时ok为false
+func optionalKey(values map[string]any, key string) (value string, ok bool) {
+	v, exists := values[key]
+	if !exists || v == nil {
+		return "", false
+	}
+	value, err := expandEnv(key, fmt.Sprint(v))
+	return value, err == nil
+}
+
+// validateAliyunCredentials keyId和keySecret需要同时配置，securityToken只能与它们一起使用；
+// 三者都不配置时使用阿里云默认凭证链
+func validateAliyunCredentials(values map[string]any) []string {
+	_, hasId := values["keyId"]
+	_, hasSecret := values["keySecret"]
+	_, hasToken := values["securityToken"]
+	problems := make([]string, 0)
+	if hasId != hasSecret {
+		problems = append(problems, "keyId and keySecret must be set together")
+	}
+	if hasToken && !hasId {
+		problems = append(problems, "securityToken requires keyId and keySecret")
+	}
+	return problems
+}
+
+// validateGandiCredentials 个人访问令牌token和旧版apiKey必须且只能配置一个
+func validateGandiCredentials(values map[string]any) []string {
+	_, hasToken := values["token"]
+	_, hasKey := values["apiKey"]
+	if hasToken == hasKey {
+		return []string{"exactly one of token and apiKey must be set"}
+	}
+	return nil
+}
+
+// requiredNotifyKeys 各类型通知必须配置的config项
+var requiredNotifyKeys = map[string][]string{
+	"dding":        {"url"},
+	"slack":        {"webhookUrl"},
+	"email":        {"smtpHost", "smtpPort", "username", "password", "from", "to"},
+	"wecom":        {"webhookUrl"},
+	"webhook":      {"url"},
+	"pagerduty":    {"routingKey"},
+	"matrix":       {"homeserverUrl", "accessToken", "roomId"},
+	"gotify":       {"serverUrl", "appToken"},
+	"pushover":     {"appToken", "userKey"},
+	"bark":         {"deviceKey"},
+	"serverchan":   {"sendKey"},
+	"alertmanager": {"url"},
+	"console":      {},
+	"stdout":       {},
+}
+
+// notifyValidators 必填项之外，各类型通知可选项的格式
+var notifyValidators = map[string]func(values map[string]any) []string{
+	"alertmanager": validateAlertmanagerOptions,
+	"pagerduty":    validatePagerDutyOptions,
+	"webhook":      validateWebhookOptions,
+}
+
+// validateNotifyOptions 检查各类型通知共用的可选项，构造通知时读取的都是已校验的值
+func validateNotifyOptions(values map[string]any) []string {
+	problems := make([]string, 0)
+	for _, key := range []string{"maxHosts", "maxMessages"} {
+		if v, ok := optionalKey(values, key); ok {
+			if _, err := parseBatchLimit(key, v); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	return problems
+}
+
+// checkKeys 检查必填项存在且为字符串，并且所有已配置项引用的环境变量都已设置
+func checkKeys(values map[string]any, required []string) []string {
+	problems := make([]string, 0)
+	for _, key := range required {
+		v, ok := values[key]
+		if !ok || v == nil {
+			problems = append(problems, fmt.Sprintf("missing config key %s", key))
+			continue
+		}
+		if _, ok := v.(string); !ok {
+			problems = append(problems, fmt.Sprintf("config key %s must be a string, quote the value", key))
+		}
+	}
+	return append(problems, checkEnv("", values)...)
+}
+
+// checkEnv 可选项通过GetDefault和GetMap读取时同样展开环境变量，嵌套的项以key.k的形式报告
+func checkEnv(prefix string, values map[string]any) []string {
+	problems := make([]string, 0)
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		switch v := values[key].(type) {
+		case nil:
+		case map[string]any:
+			problems = append(problems, checkEnv(prefix+key+".", v)...)
+		default:
+			if _, err := expandEnv(prefix+key, fmt.Sprint(v)); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	return problems
+}
+
+// location 只有YAML配置能得到行号
+func location(line int) string {
+	if line > 0 {
+		return fmt.Sprintf("line %d: ", line)
+	}
+	return ""
+}
+
+// Validate 一次性检查所有provider和通知的配置，返回的错误包含全部问题
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+	if c.AlertState != nil && c.AlertState.GraceDays < 0 {
+		errs = append(errs, errors.New("alertState: graceDays must not be negative"))
+	}
+	if c.ProviderConcurrency < 0 {
+		errs = append(errs, errors.New("providerConcurrency must not be negative"))
+	}
+	if c.ProviderDefaults.MaxRetry < 0 || c.ProviderDefaults.PageSize < 0 {
+		errs = append(errs, errors.New("providerDefaults: maxRetry and pageSize must not be negative"))
+	}
+	for _, option := range [][2]string{{"retryDelay", c.ProviderDefaults.RetryDelay}, {"maxRetryDelay", c.ProviderDefaults.MaxRetryDelay}} {
+		key, value := option[0], option[1]
+		if value == "" {
+			continue
+		}
+		if delay, err := time.ParseDuration(value); err != nil {
+			errs = append(errs, fmt.Errorf("providerDefaults: %s: %w", key, err))
+		} else if delay <= 0 {
+			errs = append(errs, fmt.Errorf("providerDefaults: %s %s must be positive", key, value))
+		}
+	}
+	for _, pc := range c.Providers {
+		if pc.ProviderType == aliyun && c.ProviderDefaults.PageSize > aliyunMaxPageSize {
+			errs = append(errs, fmt.Errorf("%sprovider %q: providerDefaults.pageSize must not exceed %d for aliyun", location(pc.line), pc.Name, aliyunMaxPageSize))
+		}
+		required, ok := requiredProviderKeys[pc.ProviderType]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%sprovider %q: unsupported provider type %q", location(pc.line), pc.Name, pc.ProviderType))
+			continue
+		}
+		if types, ok := pc.Addition["recordTypes"].(string); ok {
+			for _, t := range parseRecordTypes(types) {
+				if !supportedRecordTypes[t] {
+					errs = append(errs, fmt.Errorf("%sprovider %q: unsupported record type %q", location(pc.line), pc.Name, t))
+				}
+			}
+		}
+		if pc.WarnDays < 0 {
+			errs = append(errs, fmt.Errorf("%sprovider %q: warnDays must not be negative", location(pc.line), pc.Name))
+		}
+		problems := checkKeys(pc.Addition, required)
+		problems = append(problems, validateProviderOptions(pc.Addition)...)
+		if validate, ok := providerValidators[pc.ProviderType]; ok {
+			problems = append(problems, validate(pc.Addition)...)
+		}
+		for _, problem := range problems {
+			errs = append(errs, fmt.Errorf("%sprovider %q: %s", location(pc.line), pc.Name, problem))
+		}
+	}
+	if c.CheckInterval != "" {
+		if interval, err := time.ParseDuration(c.CheckInterval); err != nil {
+			errs = append(errs, fmt.Errorf("checkInterval: %w", err))
+		} else if interval <= 0 {
+			errs = append(errs, fmt.Errorf("checkInterval: %s must be positive", c.CheckInterval))
+		}
+	}
+	if _, err := ParseTLSVersion(c.MinTLSVersion); err != nil {
+		errs = append(errs, fmt.Errorf("minTLSVersion: %w", err))
+	}
+	for name, addr := range c.Endpoints {
+		if name == "" || strings.TrimSpace(addr) == "" {
+			errs = append(errs, fmt.Errorf("endpoints: %q maps to an empty address", name))
+		}
+	}
+	switch c.SunsetCheck {
+	case "", "all", sunsetLeaf, sunsetOff:
+	default:
+		errs = append(errs, fmt.Errorf("sunsetCheck: %q must be all, leaf or off", c.SunsetCheck))
+	}
+	if _, err := ParseSunsetAlgorithms(c.SunsetAlgorithms); err != nil {
+		errs = append(errs, fmt.Errorf("sunsetAlgorithms: %w", err))
+	}
+	if _, err := ParseTLSVersion(c.HandshakeMinTLSVersion); err != nil {
+		errs = append(errs, fmt.Errorf("handshakeMinTLSVersion: %w", err))
+	}
+	if c.Proxy != "" {
+		if _, err := ParseProxy(c.Proxy); err != nil {
+			errs = append(errs, fmt.Errorf("proxy: %w", err))
+		}
+	}
+	if c.WildcardLabel != "" && !wildcardLabelPattern.MatchString(c.WildcardLabel) {
+		errs = append(errs, fmt.Errorf("wildcardLabel: %q is not a valid DNS label", c.WildcardLabel))
+	}
+	for i, t := range c.Thresholds {
+		if t.Days < 0 || t.Hours < 0 || (t.Days > 0) == (t.Hours > 0) {
+			errs = append(errs, fmt.Errorf("thresholds[%d]: set either a positive days or hours", i))
+		}
+	}
+	if c.CheckToken != "" {
+		if c.HealthAddr == "" {
+			errs = append(errs, errors.New("checkToken: requires healthAddr"))
+		}
+		if _, err := expandEnv("checkToken", c.CheckToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		errs = append(errs, errors.New("clientCert and clientKey must be set together"))
+	}
+	if c.Report != nil {
+		if c.Report.Path == "" {
+			errs = append(errs, errors.New("report: missing path"))
+		}
+		if f := reportFormat(c.Report.Path, c.Report.Format); f != jsonReport && f != csvReport {
+			errs = append(errs, fmt.Errorf("report: unsupported format %q", c.Report.Format))
+		}
+	}
+	if _, err := NewHostFilter(c.Include, c.Exclude); err != nil {
+		errs = append(errs, err)
+	}
+	for _, nc := range c.Notifies {
+		required, ok := requiredNotifyKeys[nc.Type]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%sunsupported notify type %q", location(nc.line), nc.Type))
+			continue
+		}
+		problems := checkKeys(nc.Config, required)
+		problems = append(problems, validateNotifyOptions(nc.Config)...)
+		if validate, ok := notifyValidators[nc.Type]; ok {
+			problems = append(problems, validate(nc.Config)...)
+		}
+		for _, problem := range problems {
+			errs = append(errs, fmt.Errorf("%snotify %q: %s", location(nc.line), nc.Type, problem))
+		}
+	}
+	return errors.Join(errs...)
 }
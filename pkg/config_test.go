@@ -1,8 +1,266 @@
 package pkg
 
 import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewDNSProvider(t *testing.T) {
 }
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("CHECK_CERTS_SECRET", "s3cret")
+	got, err := expandEnv("keySecret", "${CHECK_CERTS_SECRET}")
+	if err != nil || got != "s3cret" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	got, err = expandEnv("url", "https://example.com/?token=${CHECK_CERTS_SECRET}&a=$b")
+	if err != nil || got != "https://example.com/?token=s3cret&a=$b" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err = expandEnv("apiKey", "${CHECK_CERTS_UNSET_VAR}"); err == nil {
+		t.Error("expected error for unset variable")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	data := `
+providers:
+  - name: ok
+    provider: file
+    config:
+      filePath: hosts
+  - name: broken
+    provider: aliyun
+    config:
+      keyId: id
+      region: cn-shenzhen
+  - name: unknown
+    provider: nope
+notifies:
+  - type: email
+    config:
+      smtpHost: smtp.example.com
+      smtpPort: 587
+      username: u
+      password: p
+      from: a@example.com
+      to: b@example.com
+providerDefaults:
+  pageSize: 1000
+  retryDelay: -1s
+checkInterval: 1d
+checkToken: token
+`
+	var config Config
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		`line 7: provider "broken": keyId and keySecret must be set together`,
+		`line 7: provider "broken": missing config key domains`,
+		`line 7: provider "broken": providerDefaults.pageSize must not exceed 500 for aliyun`,
+		`line 12: provider "unknown": unsupported provider type "nope"`,
+		`line 15: notify "email": config key smtpPort must be a string`,
+		`checkInterval: time: unknown unit "d" in duration "1d"`,
+		`checkToken: requires healthAddr`,
+		`providerDefaults: retryDelay -1s must be positive`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%s", want, err)
+		}
+	}
+}
+
+func TestConfig_ValidateOptions(t *testing.T) {
+	data := `
+providers:
+  - name: aliyun
+    provider: aliyun
+    config:
+      region: cn-shenzhen
+      domains: example.com
+      qps: fast
+  - name: west
+    provider: west
+    config:
+      apiKey: key
+      domains: example.com
+      concurrency: 0
+notifies:
+  - type: dding
+    config:
+      url: https://oapi.dingtalk.com/robot/send
+      maxHosts: -1
+      secret: ${CHECK_CERTS_UNSET_VAR}
+  - type: alertmanager
+    config:
+      url: http://alertmanager:9093/api/v2/alerts
+      resolveAfter: 2d
+  - type: pagerduty
+    config:
+      routingKey: key
+      criticalDays: a week
+  - type: webhook
+    config:
+      url: https://example.com/hook
+      method: patch
+      headers:
+        Authorization: Bearer ${CHECK_CERTS_UNSET_VAR}
+`
+	var config Config
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		`line 3: provider "aliyun": qps "fast" must be a positive number`,
+		`line 9: provider "west": concurrency "0" must be a positive integer`,
+		`line 16: notify "dding": maxHosts "-1" must be a non-negative number`,
+		`line 16: notify "dding": config key secret references unset environment variable CHECK_CERTS_UNSET_VAR`,
+		`line 21: notify "alertmanager": resolveAfter "2d" must be a positive duration`,
+		`line 25: notify "pagerduty": criticalDays "a week" must be a number`,
+		`line 29: notify "webhook": method "patch" must be POST or PUT`,
+		`line 29: notify "webhook": config key headers.Authorization references unset environment variable CHECK_CERTS_UNSET_VAR`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%s", want, err)
+		}
+	}
+}
+
+func TestConfig_Thresholds(t *testing.T) {
+	data := `
+thresholds:
+  - days: 30
+    severity: warning
+  - hours: 48
+    severity: Critical
+`
+	var config Config
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	want := []Threshold{{Days: 30, Severity: SeverityWarning}, {Hours: 48, Severity: SeverityCritical}}
+	if !slices.Equal(config.Thresholds, want) {
+		t.Errorf("expected %+v, got %+v", want, config.Thresholds)
+	}
+	config.Thresholds = append(config.Thresholds, Threshold{Days: 1, Hours: 1})
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "thresholds[2]: set either a positive days or hours") {
+		t.Errorf("expected threshold error, got %v", err)
+	}
+	if err := yaml.Unmarshal([]byte("thresholds: [{days: 7, severity: urgent}]"), &config); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
+
+func TestConfig_Interval(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": 24 * time.Hour, "12h": 12 * time.Hour, "90m": 90 * time.Minute, "-1h": 24 * time.Hour} {
+		if got := (&Config{CheckInterval: value}).Interval(); got != want {
+			t.Errorf("checkInterval %q: expected %s, got %s", value, want, got)
+		}
+	}
+}
+
+func TestParseConfig_Formats(t *testing.T) {
+	sources := map[string]string{
+		".yaml": `
+timeout: 10
+warnDays: 10
+checkOCSP: true
+providers:
+  - name: local-file
+    provider: file
+    config:
+      filePath: hosts
+notifies:
+  - type: dding
+    config:
+      url: https://example.com/robot
+`,
+		".json": `{
+  "timeout": 10,
+  "warnDays": 10,
+  "checkOCSP": true,
+  "providers": [{"name": "local-file", "provider": "file", "config": {"filePath": "hosts"}}],
+  "notifies": [{"type": "dding", "config": {"url": "https://example.com/robot"}}]
+}`,
+		".toml": `
+timeout = 10
+warnDays = 10
+checkOCSP = true
+
+[[providers]]
+name = "local-file"
+provider = "file"
+config = { filePath = "hosts" }
+
+[[notifies]]
+type = "dding"
+config = { url = "https://example.com/robot" }
+`,
+	}
+	configs := make(map[string]*Config)
+	for ext, data := range sources {
+		config, err := parseConfig(ext, []byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		// 行号只有YAML才有，不参与比较
+		for _, pc := range config.Providers {
+			pc.line = 0
+		}
+		for _, nc := range config.Notifies {
+			nc.line = 0
+		}
+		configs[ext] = config
+	}
+	for _, ext := range []string{".json", ".toml"} {
+		if !reflect.DeepEqual(configs[".yaml"], configs[ext]) {
+			t.Errorf("%s config differs from yaml:\n%+v\n%+v", ext, configs[ext], configs[".yaml"])
+		}
+	}
+}
+
+func TestValidateGandiCredentials(t *testing.T) {
+	for values, valid := range map[string]bool{"token": true, "apiKey": true, "": false, "token,apiKey": false} {
+		config := map[string]any{}
+		for _, key := range strings.Split(values, ",") {
+			if key != "" {
+				config[key] = "secret"
+			}
+		}
+		if problems := validateGandiCredentials(config); (len(problems) == 0) != valid {
+			t.Errorf("validateGandiCredentials(%v) = %v", config, problems)
+		}
+	}
+}
+
+func TestValidateAliyunCredentials(t *testing.T) {
+	cases := []struct {
+		values map[string]any
+		valid  bool
+	}{
+		{map[string]any{}, true},
+		{map[string]any{"keyId": "id", "keySecret": "secret"}, true},
+		{map[string]any{"keyId": "id", "keySecret": "secret", "securityToken": "token"}, true},
+		{map[string]any{"keySecret": "secret"}, false},
+		{map[string]any{"securityToken": "token"}, false},
+	}
+	for _, c := range cases {
+		if problems := validateAliyunCredentials(c.values); (len(problems) == 0) != c.valid {
+			t.Errorf("validateAliyunCredentials(%v) = %v", c.values, problems)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// HostSet 记录一轮检查中已经出现过的host，多个provider可以并发写入
+type HostSet struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func NewHostSet() *HostSet {
+	return &HostSet{seen: make(map[string]struct{})}
+}
+
+// Add host第一次出现时返回true
+func (hs *HostSet) Add(host string) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if _, ok := hs.seen[host]; ok {
+		return false
+	}
+	hs.seen[host] = struct{}{}
+	return true
+}
+
+// hosts 已经出现过的全部host
+func (hs *HostSet) hosts() map[string]bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hosts := make(map[string]bool, len(hs.seen))
+	for host := range hs.seen {
+		hosts[host] = true
+	}
+	return hosts
+}
+
+// normalizeHost 转为小写并去掉域名结尾的点，a.com.和A.com视为同一个host。本地证书文件的路径区分大小写，保持不变
+func normalizeHost(host string) string {
+	if scheme, _ := splitScheme(host); scheme == fileScheme {
+		return strings.TrimSpace(host)
+	}
+	host = strings.ToLower(strings.TrimSpace(host))
+	if hostname, port, err := net.SplitHostPort(host); err == nil {
+		return net.JoinHostPort(strings.TrimRight(hostname, "."), port)
+	}
+	return strings.TrimRight(host, ".")
+}
+
+// NewHost 归一化provider返回的记录并标记泛域名，无法检查的记录返回空的Host。
+// provider原样返回泛域名记录，由这里和检查时的parseTarget决定如何处理
+func NewHost(record string, warnDays int) Host {
+	host := normalizeHost(record)
+	if !checkable(host) {
+		return Host{}
+	}
+	return Host{Host: host, WarnDays: warnDays, Wildcard: isWildcard(host)}
+}
+
+// checkable 空、@开头（provider没有展开的apex）和只有*的记录无法检查
+func checkable(host string) bool {
+	return host != "" && host[0] != '@' && parseTarget(host, "", false).addr != ""
+}
+
+// isWildcard 与检查时一致，SNI为泛域名时是泛域名记录，例如*.example.com和*.example.com/10.0.0.1
+func isWildcard(host string) bool {
+	return parseTarget(host, "", false).wildcard != ""
+}
+
+// Dedup 把in中的host归一化、去重后写入out，in关闭或ctx取消后关闭out。
+// 多个provider返回同一个host时，以最先到达的告警天数为准
+func Dedup(ctx context.Context, in <-chan Host, out chan<- Host, seen *HostSet) {
+	defer close(out)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case host, ok := <-in:
+			if !ok {
+				return
+			}
+			host.Host = normalizeHost(host.Host)
+			if host.Host == "" || !seen.Add(host.Host) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- host:
+			}
+		}
+	}
+}
+
+// hostPattern 匹配host的域名部分，/.../为正则表达式，.开头为后缀匹配（.example.com匹配example.com及其子域名），
+// 否则为通配符，*可以匹配任意字符，不含*时需要完全一致
+type hostPattern struct {
+	glob   string
+	suffix string
+	re     *regexp.Regexp
+}
+
+func parseHostPattern(pattern string) (hostPattern, error) {
+	pattern = strings.TrimSpace(pattern)
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return hostPattern{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return hostPattern{re: re}, nil
+	}
+	glob := strings.ToLower(strings.TrimRight(pattern, "."))
+	if strings.HasPrefix(glob, ".") && !strings.Contains(glob, "*") {
+		return hostPattern{suffix: glob}, nil
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return hostPattern{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return hostPattern{glob: glob}, nil
+}
+
+func (hp hostPattern) match(name string) bool {
+	if hp.re != nil {
+		return hp.re.MatchString(name)
+	}
+	if hp.suffix != "" {
+		return name == hp.suffix[1:] || strings.HasSuffix(name, hp.suffix)
+	}
+	ok, _ := path.Match(hp.glob, name)
+	return ok
+}
+
+// hostName 去掉scheme、端口、SNI和hostname/ip中的ip，只保留用于匹配的域名
+func hostName(host string) string {
+	scheme, host := splitScheme(host)
+	if i := strings.LastIndex(host, "@"); i > 0 {
+		host = host[:i]
+	}
+	if name, _, ok := splitNodeAddr(host); ok && scheme != fileScheme {
+		return name
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.Trim(host, "[]")
+}
+
+// HostFilter 按配置的include和exclude规则过滤host
+type HostFilter struct {
+	include []hostPattern
+	exclude []hostPattern
+}
+
+func NewHostFilter(include, exclude []string) (*HostFilter, error) {
+	hf := &HostFilter{}
+	var err error
+	if hf.include, err = parseHostPatterns(include); err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	if hf.exclude, err = parseHostPatterns(exclude); err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+	return hf, nil
+}
+
+func parseHostPatterns(patterns []string) ([]hostPattern, error) {
+	hps := make([]hostPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		hp, err := parseHostPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		hps = append(hps, hp)
+	}
+	return hps, nil
+}
+
+func matchAny(patterns []hostPattern, name string) bool {
+	for _, hp := range patterns {
+		if hp.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow 配置了include时host需要匹配其中之一，同时匹配include和exclude时以exclude为准
+func (hf *HostFilter) Allow(host string) bool {
+	name := hostName(host)
+	if len(hf.include) > 0 && !matchAny(hf.include, name) {
+		return false
+	}
+	return !matchAny(hf.exclude, name)
+}
+
+// Filter 丢弃不符合规则的host，其余写入out，in关闭或ctx取消后关闭out
+func Filter(ctx context.Context, in <-chan Host, out chan<- Host, hf *HostFilter) {
+	defer close(out)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case host, ok := <-in:
+			if !ok {
+				return
+			}
+			if !hf.Allow(host.Host) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- host:
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	in := make(chan Host, 10)
+	out := make(chan Host, 10)
+	for _, host := range []string{"a.com", "A.com.", "b.com:8443", "B.COM.:8443", "a.com", "c.com"} {
+		in <- Host{Host: host}
+	}
+	close(in)
+	Dedup(context.Background(), in, out, NewHostSet())
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host.Host)
+	}
+	want := []string{"a.com", "b.com:8443", "c.com"}
+	if len(hosts) != len(want) {
+		t.Fatalf("got %v, want %v", hosts, want)
+	}
+	for i := range want {
+		if hosts[i] != want[i] {
+			t.Errorf("got %v, want %v", hosts, want)
+		}
+	}
+}
+
+func TestHostFilter_Exclude(t *testing.T) {
+	hf, err := NewHostFilter(nil, []string{"autodiscover.*", "*.cdn.example.com", "exact.example.com", `/^test[0-9]+\./`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"autodiscover.example.com:443":   false,
+		"a.cdn.example.com":              false,
+		"cdn.example.com":                true,
+		"exact.example.com:8443@sni.com": false,
+		"smtp://exact.example.com:587":   false,
+		"www.exact.example.com":          true,
+		"exact.example.com/10.0.0.1:443": false,
+		"test12.example.com":             false,
+		"www.example.com":                true,
+	}
+	for host, want := range cases {
+		if got := hf.Allow(host); got != want {
+			t.Errorf("Allow(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if _, err = NewHostFilter(nil, []string{"/([a-z/"}); err == nil {
+		t.Error("expected invalid regex error")
+	}
+}
+
+func TestHostFilter_Include(t *testing.T) {
+	hf, err := NewHostFilter([]string{".example.com", "api.*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"example.com":         true,
+		"www.example.com:443": true,
+		"badexample.com":      false,
+		"api.other.org":       true,
+		"www.other.org":       false,
+	}
+	for host, want := range cases {
+		if got := hf.Allow(host); got != want {
+			t.Errorf("Allow(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestHostFilter_IncludeAndExclude(t *testing.T) {
+	hf, err := NewHostFilter([]string{".example.com"}, []string{"*.dev.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"www.example.com":     true,
+		"app.dev.example.com": false,
+		"www.other.org":       false,
+	}
+	for host, want := range cases {
+		if got := hf.Allow(host); got != want {
+			t.Errorf("Allow(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const errCertChanged = "certificate changed unexpectedly, previous fingerprint %s"
+
+// Fingerprint 证书DER编码的SHA-256，十六进制小写
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+type fingerprintEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	NotAfter    time.Time `json:"notAfter"`
+}
+
+// FingerprintStore 记录每个host上一次检查到的叶子证书，用于发现计划外的证书更换。
+// 配置了path时每轮结束后写入文件，重启后仍能与上一次运行比较
+type FingerprintStore struct {
+	mu            sync.Mutex
+	path          string
+	alertOnChange bool
+	seen          map[string]fingerprintEntry
+}
+
+// NewFingerprintStore path为空时只保存在内存中，文件不存在时从空状态开始
+func NewFingerprintStore(path string, alertOnChange bool) (*FingerprintStore, error) {
+	fps := &FingerprintStore{path: path, alertOnChange: alertOnChange, seen: make(map[string]fingerprintEntry)}
+	if path == "" {
+		return fps, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fps, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &fps.seen); err != nil {
+		return nil, err
+	}
+	return fps, nil
+}
+
+// observe 记录host本次的叶子证书，返回上一次的指纹以及这次更换是否在计划之外。
+// 旧证书已经进入warnDays或已过期时的更换视为正常续期
+func (fps *FingerprintStore) observe(host string, cert *x509.Certificate, warnDays int, now time.Time) (string, bool) {
+	fingerprint := Fingerprint(cert)
+	fps.mu.Lock()
+	defer fps.mu.Unlock()
+	prev, ok := fps.seen[host]
+	fps.seen[host] = fingerprintEntry{Fingerprint: fingerprint, NotAfter: cert.NotAfter}
+	if !ok || prev.Fingerprint == fingerprint {
+		return "", false
+	}
+	renewal := now.AddDate(0, 0, warnDays).After(prev.NotAfter)
+	slog.Info("certificate changed", "host", host, "previous", prev.Fingerprint, "current", fingerprint, "renewal", renewal)
+	return prev.Fingerprint, !renewal
+}
+
+// Save 在一轮检查完整结束后调用，本轮没有检查到的host保留上一次的记录
+func (fps *FingerprintStore) Save() error {
+	if fps.path == "" {
+		return nil
+	}
+	fps.mu.Lock()
+	data, err := json.MarshalIndent(fps.seen, "", "  ")
+	fps.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fps.path, data)
+}
+
+// checkFingerprint 证书在计划之外被更换并且配置了alertOnChange时告警
+func (sc *SimpleCheck) checkFingerprint(host string, leaf *x509.Certificate, warnDays int, now time.Time) {
+	prev, unexpected := sc.Fingerprints.observe(host, leaf, warnDays, now)
+	if unexpected && sc.Fingerprints.alertOnChange {
+		sc.out <- newCertResult(host, fmt.Sprintf(errCertChanged, prev), SeverityWarning, leaf, now)
+	}
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFingerprintStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fingerprints.json")
+	fps, err := NewFingerprintStore(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	first := newTestCert(t, []string{"a.com"}, now.Add(-time.Hour), now.AddDate(0, 0, 60)).Leaf
+	if _, unexpected := fps.observe("a.com:443", first, 10, now); unexpected {
+		t.Error("first certificate reported as changed")
+	}
+	if err = fps.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后从文件恢复，同一张证书不算更换
+	if fps, err = NewFingerprintStore(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, unexpected := fps.observe("a.com:443", first, 10, now); unexpected {
+		t.Error("same certificate reported as changed")
+	}
+	second := newTestCert(t, []string{"a.com"}, now.Add(-time.Hour), now.AddDate(0, 0, 90)).Leaf
+	if prev, unexpected := fps.observe("a.com:443", second, 10, now); !unexpected || prev != Fingerprint(first) {
+		t.Errorf("early replacement not reported, previous %q", prev)
+	}
+	// second还剩5天时更换视为续期
+	third := newTestCert(t, []string{"a.com"}, now.Add(-time.Hour), now.AddDate(0, 0, 180)).Leaf
+	if _, unexpected := fps.observe("a.com:443", third, 10, now.AddDate(0, 0, 85)); unexpected {
+		t.Error("renewal within warnDays reported as unexpected")
+	}
+}
+
+func TestCheckHostHttps_FingerprintChanged(t *testing.T) {
+	now := time.Now()
+	first := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	second := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(1, 0, 0))
+	var current atomic.Pointer[tls.Certificate]
+	current.Store(&first)
+	addr := startTLSServer(t, &tls.Config{GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return current.Load(), nil
+	}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	fps, err := NewFingerprintStore("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan CheckResult, 10)
+	sc := NewSimpleCheck(nil, out)
+	sc.RootCAs = x509.NewCertPool()
+	sc.RootCAs.AddCert(first.Leaf)
+	sc.RootCAs.AddCert(second.Leaf)
+	sc.Fingerprints = fps
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	if results := collectResults(out, 100*time.Millisecond); len(results) != 0 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	current.Store(&second)
+	sc.checkHostHttps(context.Background(), "localhost:"+port, 10)
+	results := collectResults(out, 100*time.Millisecond)
+	if len(results) != 1 || !strings.Contains(results[0].WarnMsg, Fingerprint(first.Leaf)) {
+		t.Fatalf("expected certificate change, got %+v", results)
+	}
+	if results[0].SHA256Fingerprint != Fingerprint(second.Leaf) || results[0].Severity != SeverityWarning {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+}
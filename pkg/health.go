@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health 记录主循环的运行状态，用于Kubernetes的存活和就绪探针，
+// 配置了checkToken时还可以通过POST /check立即开始一轮检查
+type Health struct {
+	mu          sync.Mutex
+	alive       bool
+	lastSuccess time.Time
+	cycles      int
+	running     bool
+	checkToken  string
+	requests    chan struct{}
+}
+
+func NewHealth() *Health {
+	return &Health{requests: make(chan struct{}, 1)}
+}
+
+// SetCheckToken 设置POST /check使用的Bearer token，为空时关闭该接口
+func (h *Health) SetCheckToken(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkToken = token
+}
+
+// CheckRequests 收到POST /check后可以读出一个值，检查开始前的多次请求只触发一轮
+func (h *Health) CheckRequests() <-chan struct{} {
+	return h.requests
+}
+
+// CycleStarted 一轮检查开始时调用，结束前收到的POST /check返回409
+func (h *Health) CycleStarted() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.running = true
+}
+
+// CycleFinished 一轮检查结束时调用，包括被取消的检查
+func (h *Health) CycleFinished() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.running = false
+}
+
+// SetAlive 主循环开始时设置为true，退出时设置为false
+func (h *Health) SetAlive(alive bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.alive = alive
+}
+
+// CycleDone 一轮检查完整结束后调用
+func (h *Health) CycleDone(t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSuccess = t
+	h.cycles++
+}
+
+type healthStatus struct {
+	Status              string     `json:"status"`
+	LastSuccessfulCycle *time.Time `json:"lastSuccessfulCycle,omitempty"`
+	Cycles              int        `json:"cycles"`
+}
+
+func (h *Health) status(ok bool) (int, healthStatus) {
+	status := healthStatus{Status: "ok", Cycles: h.cycles}
+	if !h.lastSuccess.IsZero() {
+		last := h.lastSuccess
+		status.LastSuccessfulCycle = &last
+	}
+	if !ok {
+		status.Status = "unavailable"
+		return http.StatusServiceUnavailable, status
+	}
+	return http.StatusOK, status
+}
+
+// requestCheck 检查正在进行时返回409，不会重复获取provider的记录，否则返回202
+func (h *Health) requestCheck(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	token, running := h.checkToken, h.running
+	h.mu.Unlock()
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if running {
+		http.Error(w, "check already running", http.StatusConflict)
+		return
+	}
+	select {
+	case h.requests <- struct{}{}:
+		slog.Info("check requested", "remote", r.RemoteAddr)
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Handler /healthz在主循环运行时返回200，/readyz在至少完成一轮检查后返回200，否则返回503
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	write := func(w http.ResponseWriter, code int, status healthStatus) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(status)
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		h.mu.Lock()
+		code, status := h.status(h.alive)
+		h.mu.Unlock()
+		write(w, code, status)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		h.mu.Lock()
+		code, status := h.status(h.alive && h.cycles > 0)
+		h.mu.Unlock()
+		write(w, code, status)
+	})
+	mux.HandleFunc("/check", h.requestCheck)
+	return mux
+}
+
+// ServeHealth 在addr上提供/healthz、/readyz和/check，会一直阻塞
+func ServeHealth(addr string, h *Health) {
+	slog.Debug("health listen", "addr", addr)
+	if err := http.ListenAndServe(addr, h.Handler()); err != nil {
+		slog.Error("health server stopped", "error", err)
+	}
+}
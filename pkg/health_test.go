@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth_Handler(t *testing.T) {
+	h := NewHealth()
+	get := func(path string) (int, healthStatus) {
+		rec := httptest.NewRecorder()
+		h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var status healthStatus
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, status
+	}
+	if code, _ := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("healthz before start: %d", code)
+	}
+	h.SetAlive(true)
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz after start: %d", code)
+	}
+	if code, status := get("/readyz"); code != http.StatusServiceUnavailable || status.LastSuccessfulCycle != nil {
+		t.Errorf("readyz before first cycle: %d %+v", code, status)
+	}
+	done := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.CycleDone(done)
+	code, status := get("/readyz")
+	if code != http.StatusOK || status.Cycles != 1 || status.LastSuccessfulCycle == nil || !status.LastSuccessfulCycle.Equal(done) {
+		t.Errorf("readyz after first cycle: %d %+v", code, status)
+	}
+}
+
+func TestHealth_Check(t *testing.T) {
+	h := NewHealth()
+	post := func(method, auth string) int {
+		req := httptest.NewRequest(method, "/check", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post(http.MethodPost, "Bearer secret"); code != http.StatusNotFound {
+		t.Errorf("check without token: %d", code)
+	}
+	h.SetCheckToken("secret")
+	if code := post(http.MethodGet, "Bearer secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("check with GET: %d", code)
+	}
+	if code := post(http.MethodPost, "Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("check with wrong token: %d", code)
+	}
+	// 检查开始前的多次请求合并为一次
+	for i := 0; i < 2; i++ {
+		if code := post(http.MethodPost, "Bearer secret"); code != http.StatusAccepted {
+			t.Errorf("check request %d: %d", i, code)
+		}
+	}
+	<-h.CheckRequests()
+	select {
+	case <-h.CheckRequests():
+		t.Error("expected a single pending request")
+	default:
+	}
+	h.CycleStarted()
+	if code := post(http.MethodPost, "Bearer secret"); code != http.StatusConflict {
+		t.Errorf("check while running: %d", code)
+	}
+	h.CycleFinished()
+	if code := post(http.MethodPost, "Bearer secret"); code != http.StatusAccepted {
+		t.Errorf("check after the cycle: %d", code)
+	}
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hstsMaxAge 解析Strict-Transport-Security头中的max-age，没有该指令时返回false
+func hstsMaxAge(header string) (time.Duration, bool) {
+	for _, directive := range strings.Split(header, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(value), `"`), 10, 64)
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+// checkHSTS 复用已经完成握手的连接发送GET请求，请求失败只记录日志，不影响证书检查的结果
+func (sc *SimpleCheck) checkHSTS(conn *tls.Conn, host, serverName string) {
+	minMaxAge := sc.MinHSTSMaxAge
+	if minMaxAge <= 0 {
+		minMaxAge = defaultMinHSTSMaxAge
+	}
+	if err := conn.SetDeadline(time.Now().Add(sc.timeout())); err != nil {
+		slog.Warn("skip HSTS check", "host", host, "error", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://"+serverName+"/", nil)
+	if err != nil {
+		slog.Warn("skip HSTS check", "host", host, "error", err)
+		return
+	}
+	req.Header.Set("User-Agent", "go-check-certs")
+	req.Close = true
+	if err = req.Write(conn); err != nil {
+		slog.Warn("skip HSTS check", "host", host, "error", err)
+		return
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		slog.Warn("skip HSTS check", "host", host, "error", err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	header := resp.Header.Get("Strict-Transport-Security")
+	maxAge, ok := hstsMaxAge(header)
+	switch {
+	case header == "" || !ok:
+		sc.out <- CheckResult{Host: host, WarnMsg: fmt.Sprintf(errHSTS, "missing"), Severity: SeverityInfo}
+	case maxAge < minMaxAge:
+		sc.out <- CheckResult{Host: host, WarnMsg: fmt.Sprintf(errHSTS, "max-age="+strconv.FormatInt(int64(maxAge/time.Second), 10)), Severity: SeverityInfo}
+	}
+}
@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const jsonLogFormat = "json"
+
+// SetupLogger 设置默认logger，level为debug/info/warn/error，默认为debug；
+// format为json时输出JSON行，否则为key=value文本。环境变量LOG_LEVEL优先于配置
+func SetupLogger(level, format string) {
+	if env := os.Getenv("LOG_LEVEL"); env != "" {
+		level = env
+	}
+	logLevel := slog.LevelDebug
+	if level != "" {
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			logLevel = slog.LevelDebug
+			defer slog.Warn("unknown log level, fallback to debug", "level", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	if strings.EqualFold(format, jsonLogFormat) {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+type loggerKey struct{}
+
+// withLogAttrs 返回的ctx中的logger附加了args，provider在ctx中逐层加上域名、记录类型和分页，
+// 接口请求的日志都带有这些属性
+func withLogAttrs(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey{}, ctxLogger(ctx).With(args...))
+}
+
+// ctxLogger ctx中没有logger时使用默认logger
+func ctxLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// newEnumerationID 每次获取provider记录随机生成，用于关联同一次获取中的全部日志
+func newEnumerationID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// fatal 记录错误后退出，用于无法继续运行的配置错误
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
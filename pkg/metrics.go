@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+var (
+	certExpiryDays = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cert_expiry_days",
+		Help: "Days until the leaf certificate served by the host expires.",
+	}, []string{"host"})
+	checkFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cert_check_failures_total",
+		Help: "Number of checks that could not complete the TLS handshake.",
+	}, []string{"host"})
+	tlsNegotiated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cert_tls_negotiated_info",
+		Help: "TLS version and cipher suite negotiated with the host, always 1.",
+	}, []string{"host", "version", "cipher"})
+	notificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cert_notifications_sent_total",
+		Help: "Number of notifications sent successfully.",
+	}, []string{"notify"})
+	notificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cert_notification_failures_total",
+		Help: "Number of notifications that failed to send.",
+	}, []string{"notify"})
+	notificationLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cert_notification_last_success_timestamp_seconds",
+		Help: "Unix time of the last notification sent successfully.",
+	}, []string{"notify"})
+)
+
+// metricHosts 上一轮检查后保留了指标的host
+var (
+	metricHostsMu sync.Mutex
+	metricHosts   = make(map[string]bool)
+)
+
+func init() {
+	prometheus.MustRegister(certExpiryDays, checkFailures, tlsNegotiated, notificationsSent, notificationFailures, notificationLastSuccess)
+}
+
+// pruneHostMetrics 删除checked以外的host的指标，避免不再检查的host一直导出旧的值，标签数量不断增长
+func pruneHostMetrics(checked map[string]bool) {
+	metricHostsMu.Lock()
+	defer metricHostsMu.Unlock()
+	for host := range metricHosts {
+		if !checked[host] {
+			certExpiryDays.DeleteLabelValues(host)
+			checkFailures.DeleteLabelValues(host)
+			tlsNegotiated.DeletePartialMatch(prometheus.Labels{"host": host})
+		}
+	}
+	metricHosts = checked
+}
+
+// notifySent 记录name的一次成功发送
+func notifySent(name string) {
+	notificationsSent.WithLabelValues(name).Inc()
+	notificationLastSuccess.WithLabelValues(name).SetToCurrentTime()
+}
+
+// notifyFailed 记录name的一次发送失败，通知配置错误时可以根据该指标告警
+func notifyFailed(name string, err error, args ...any) {
+	notificationFailures.WithLabelValues(name).Inc()
+	slog.Error("notify send failed", append([]any{"notify", name}, append(args, "error", err)...)...)
+}
+
+// ServeMetrics 在addr上通过/metrics提供Prometheus指标，会一直阻塞
+func ServeMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	slog.Debug("metrics listen", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("metrics server stopped", "error", err)
+	}
+}
@@ -2,10 +2,17 @@ package pkg
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,70 +20,433 @@ import (
 const (
 	contentType    = "application/json"
 	defaultTimeout = time.Second * 5
+	textFormat     = "text"
+	markdownFormat = "markdown"
+	ddingTitle     = "SSL certificate check"
+	ddingMaxBytes  = 20000
 )
 
+// NewNotify 按类型创建通知，聊天类通知读取maxHosts和maxMessages，不支持的类型返回nil
 func NewNotify(config *NotifyConfig, in <-chan CheckResult) Notifier {
+	n := newNotifier(config, in)
+	if b, ok := n.(batchedNotifier); ok {
+		b.setBatchLimits(parseBatchLimits(config))
+	}
+	return n
+}
+
+func newNotifier(config *NotifyConfig, in <-chan CheckResult) Notifier {
 	switch config.Type {
 	case "dding":
 		return &DDingNotify{
+			ch:     in,
+			url:    config.Get("url"),
+			secret: config.GetDefault("secret", ""),
+			format: config.GetDefault("format", textFormat),
+		}
+	case "slack":
+		return &SlackNotify{
 			ch:  in,
-			url: config.Get("url"),
+			url: config.Get("webhookUrl"),
 		}
+	case "email":
+		return newEmailNotify(config, in)
+	case "wecom":
+		return newWeComNotify(config.Get("webhookUrl"), in)
+	case "webhook":
+		return newWebhookNotify(config, in)
+	case "pagerduty":
+		return newPagerDutyNotify(config, in)
+	case "matrix":
+		return newMatrixNotify(config, in)
+	case "gotify":
+		return newGotifyNotify(config, in)
+	case "pushover":
+		return newPushoverNotify(config, in)
+	case "bark":
+		return newBarkNotify(config, in)
+	case "serverchan":
+		return newServerChanNotify(config, in)
+	case "alertmanager":
+		return newAlertmanagerNotify(config, in)
+	case "console", "stdout":
+		return newConsoleNotify(in)
 	}
 	return nil
 }
 
 type Notifier interface {
-	Send(waitTime time.Duration)
+	Send(ctx context.Context, waitTime time.Duration) // ctx取消后发送剩余的消息并返回
+}
+
+// marker 通知输入中的轮次标记，与结果经过同一个Broadcast，到达每个通知时本轮的结果都已先到达
+type marker int
+
+const (
+	markerNone     marker = iota
+	markerCycleEnd        // 一轮检查完整结束
+	markerFlush           // 一轮检查完整结束，并立即发送缓存的结果，不等待waitTime
+)
+
+// CycleEnd 一轮检查结束的标记，在本轮的结果之后发给Broadcast的输入。
+// 需要区分检查轮次的通知收到后处理本轮，例如resolve本轮没有告警的host；
+// flush为true时缓存结果的通知立即发送，本轮的结果不会被拆到下一次发送
+func CycleEnd(flush bool) CheckResult {
+	if flush {
+		return CheckResult{marker: markerFlush}
+	}
+	return CheckResult{marker: markerCycleEnd}
+}
+
+// isMarker 结果是否为轮次标记，标记不是检查结果，通知不能发送它
+func isMarker(res CheckResult) bool {
+	return res.marker != markerNone
+}
+
+// Broadcast 把in中的每个结果依次发给所有outs，每个通知都能收到全部结果。
+// outs应该是无缓冲的，发送完成即表示通知已经收下该结果。in关闭或ctx取消后返回，不关闭outs
+func Broadcast(ctx context.Context, in <-chan CheckResult, outs []chan<- CheckResult) {
+	for {
+		select {
+		case res, ok := <-in:
+			if !ok {
+				return
+			}
+			for _, out := range outs {
+				select {
+				case out <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 type DDingNotify struct {
-	ch  <-chan CheckResult
-	url string
+	batchLimits
+	ch     <-chan CheckResult
+	url    string
+	secret string
+	format string
+}
+
+func (dn *DDingNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "dding", dn.ch, waitTime, dn.batchLimits, func(groups []resultGroup) error {
+		for _, msg := range dn.messages(groups) {
+			if err := dn.post(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type ddingResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// post 钉钉出错时仍返回200，需要检查返回的errcode
+func (dn *DDingNotify) post(msg *DMessage) error {
+	body, err := postJSON(dn.signedURL(time.Now()), msg.Encode())
+	if err != nil {
+		return err
+	}
+	dr := new(ddingResponse)
+	if err = json.Unmarshal(body, dr); err != nil {
+		return fmt.Errorf("unexpected dding response: %w", err)
+	}
+	if dr.ErrCode != 0 {
+		return fmt.Errorf("dding error %d: %s", dr.ErrCode, dr.ErrMsg)
+	}
+	return nil
+}
+
+// messages 按配置的格式生成消息，超过钉钉单条消息长度限制时拆分为多条
+func (dn *DDingNotify) messages(groups []resultGroup) []*DMessage {
+	lines := make([]string, 0)
+	for _, group := range groups {
+		if dn.format == markdownFormat {
+			lines = append(lines, "### "+group.WarnMsg)
+			for _, host := range group.Hosts {
+				lines = append(lines, "- "+host)
+			}
+		} else {
+			lines = append(lines, group.WarnMsg)
+			lines = append(lines, group.Hosts...)
+		}
+	}
+	msgs := make([]*DMessage, 0)
+	for _, chunk := range chunkLines(lines, ddingMaxBytes) {
+		if dn.format == markdownFormat {
+			msgs = append(msgs, newDMarkdownMessage(ddingTitle, chunk, nil, false))
+		} else {
+			msgs = append(msgs, newDMessage(chunk, nil, false))
+		}
+	}
+	return msgs
+}
+
+// signedURL 配置了加签secret时，在url后追加timestamp和sign参数
+func (dn *DDingNotify) signedURL(now time.Time) string {
+	if dn.secret == "" {
+		return dn.url
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	query := url.Values{}
+	query.Set("timestamp", timestamp)
+	query.Set("sign", ddingSign(timestamp, dn.secret))
+	sep := "?"
+	if strings.Contains(dn.url, "?") {
+		sep = "&"
+	}
+	return dn.url + sep + query.Encode()
+}
+
+// ddingSign 钉钉加签：把timestamp+"\n"+secret用secret做HmacSHA256后再Base64
+func ddingSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type resultGroup struct {
+	WarnMsg string
+	Hosts   []string      // 用于展示的host，带有证书签发者，汇总通知没有host
+	Results []CheckResult // 原始结果，除汇总通知外与Hosts一一对应
+}
+
+// hostLabel 结果带有证书签发者时一并展示，便于区分公共CA和内部CA签发的证书；
+// 带有主题时也一并展示，便于发现多个host共用的证书
+func hostLabel(res CheckResult) string {
+	details := make([]string, 0, 2)
+	if res.Subject != "" {
+		details = append(details, "subject: "+res.Subject)
+	}
+	if res.Issuer != "" {
+		details = append(details, "issuer: "+res.Issuer)
+	}
+	if len(details) == 0 {
+		return res.Host
+	}
+	return fmt.Sprintf("%s (%s)", res.Host, strings.Join(details, ", "))
+}
+
+// batchLimits 每次发送的上限，避免首次运行时大量告警拼成一条被webhook拒绝的超长消息
+type batchLimits struct {
+	maxHosts    int // 每次发送最多包含的结果数，超出时在同一次flush中拆分为多次发送，为0时不限制
+	maxMessages int // 每次flush最多发送的次数，超出的结果留到下一次flush，为0时不限制
+}
+
+func (bl *batchLimits) setBatchLimits(limits batchLimits) {
+	*bl = limits
 }
 
-func (dn *DDingNotify) Send(waitTime time.Duration) {
+// batchedNotifier 支持maxHosts和maxMessages配置的通知，嵌入batchLimits即可
+type batchedNotifier interface {
+	setBatchLimits(limits batchLimits)
+}
+
+// defaultBatchLimits 各类型通知的默认上限，钉钉和企业微信机器人每分钟最多20条，Server酱免费版每天5条
+var defaultBatchLimits = map[string]batchLimits{
+	"dding":      {maxHosts: 100, maxMessages: 20},
+	"wecom":      {maxHosts: 100, maxMessages: 20},
+	"slack":      {maxHosts: 100},
+	"matrix":     {maxHosts: 100},
+	"gotify":     {maxHosts: 100},
+	"pushover":   {maxHosts: 20},
+	"bark":       {maxHosts: 20},
+	"serverchan": {maxHosts: 100, maxMessages: 5},
+}
+
+// parseBatchLimits 读取maxHosts和maxMessages，未配置时使用通知类型的默认值，格式由Config.Validate检查
+func parseBatchLimits(config *NotifyConfig) batchLimits {
+	limits := defaultBatchLimits[config.Type]
+	for _, option := range []struct {
+		key   string
+		value *int
+	}{{"maxHosts", &limits.maxHosts}, {"maxMessages", &limits.maxMessages}} {
+		if n, err := parseBatchLimit(option.key, config.GetDefault(option.key, "")); err == nil {
+			*option.value = n
+		}
+	}
+	return limits
+}
+
+func parseBatchLimit(key, v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s %q must be a non-negative number", key, v)
+	}
+	return n, nil
+}
+
+func newResultGroup(warnMsg string, results []CheckResult) resultGroup {
+	group := resultGroup{WarnMsg: warnMsg, Hosts: make([]string, 0, len(results)), Results: results}
+	for _, res := range results {
+		if !IsSummary(res) {
+			group.Hosts = append(group.Hosts, hostLabel(res))
+		}
+	}
+	return group
+}
+
+// splitBatches 按maxHosts把分组拆分为多批，同一个WarnMsg的结果可能分到相邻的几批中
+func splitBatches(groups []resultGroup, maxHosts int) [][]resultGroup {
+	if maxHosts <= 0 {
+		return [][]resultGroup{groups}
+	}
+	batches := make([][]resultGroup, 0)
+	batch := make([]resultGroup, 0)
+	size := 0
+	for _, group := range groups {
+		for results := group.Results; len(results) > 0; {
+			if size == maxHosts {
+				batches = append(batches, batch)
+				batch, size = make([]resultGroup, 0), 0
+			}
+			n := min(len(results), maxHosts-size)
+			batch = append(batch, newResultGroup(group.WarnMsg, results[:n]))
+			results = results[n:]
+			size += n
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// collect 缓存收到的结果，每隔waitTime或收到flush标记时按WarnMsg分组后交给send发送，ctx取消时发送剩余的结果后返回。
+// 结果超过limits.maxHosts时分多次发送，超过maxMessages次的留到下一次，ctx取消时不限制次数。
+// name为通知类型，用于日志和监控指标
+func collect(ctx context.Context, name string, ch <-chan CheckResult, waitTime time.Duration, limits batchLimits, send func(groups []resultGroup) error) {
+	groups := make([]resultGroup, 0)
+	index := make(map[string]int, 0)
+	add := func(msg CheckResult) {
+		i, ok := index[msg.WarnMsg]
+		if !ok {
+			i = len(groups)
+			index[msg.WarnMsg] = i
+			groups = append(groups, resultGroup{WarnMsg: msg.WarnMsg})
+		}
+		if !IsSummary(msg) {
+			groups[i].Hosts = append(groups[i].Hosts, hostLabel(msg))
+		}
+		groups[i].Results = append(groups[i].Results, msg)
+	}
+	sendAll := func(all bool) {
+		batches := splitBatches(groups, limits.maxHosts)
+		n := len(batches)
+		if !all && limits.maxMessages > 0 && n > limits.maxMessages {
+			slog.Info("too many messages, send the rest later", "notify", name, "messages", n, "maxMessages", limits.maxMessages)
+			n = limits.maxMessages
+		}
+		for _, batch := range batches[:n] {
+			if err := send(batch); err != nil {
+				notifyFailed(name, err)
+				continue
+			}
+			notifySent(name)
+		}
+		groups = make([]resultGroup, 0)
+		index = make(map[string]int, 0)
+		for _, batch := range batches[n:] {
+			for _, group := range batch {
+				for _, res := range group.Results {
+					add(res)
+				}
+			}
+		}
+	}
 	ticker := time.NewTicker(waitTime)
-	msgs := make(map[string][]string, 0)
+	defer ticker.Stop()
 	for {
 		select {
-		case msg := <-dn.ch:
-			msgs[msg.WarnMsg] = append(msgs[msg.WarnMsg], msg.Host)
-		case <-ticker.C:
-			if len(msgs) == 0 {
-				log.Println("DEBUG no messages need to be sent")
-				continue
+		case msg := <-ch:
+			switch {
+			case msg.marker == markerFlush && len(groups) > 0:
+				slog.Debug("flush requested", "notify", name)
+				sendAll(false)
+			case isMarker(msg) || isRepeated(msg):
+			default:
+				add(msg)
 			}
-			httpClient := http.Client{Timeout: defaultTimeout}
-			sendMsgs := make([]string, 0)
-			for msg, hosts := range msgs {
-				sendMsgs = append(sendMsgs, msg)
-				sendMsgs = append(sendMsgs, hosts...)
+		case <-ctx.Done():
+			if len(groups) > 0 {
+				slog.Debug("flush messages before exit", "notify", name)
+				sendAll(true)
 			}
-			msg := newDMessage(strings.Join(sendMsgs, "\n"), nil, false)
-			resp, err := httpClient.Post(dn.url, contentType, bytes.NewBuffer(msg.Encode()))
-			if err != nil {
-				log.Println("ERROR  notify send failed", err)
-			} else {
-				_re, _ := io.ReadAll(resp.Body)
-				log.Println("DEBUG notify response", string(_re))
+			return
+		case <-ticker.C:
+			if len(groups) == 0 {
+				slog.Debug("no messages need to be sent", "notify", name)
+				continue
 			}
-			msgs = make(map[string][]string, 0)
+			sendAll(false)
 		}
 	}
 }
 
+// chunkLines 按行拼接，保证每段不超过limit字节，单行超长时单独成段
+func chunkLines(lines []string, limit int) []string {
+	chunks := make([]string, 0)
+	var b strings.Builder
+	for _, line := range lines {
+		if b.Len() > 0 && b.Len()+1+len(line) > limit {
+			chunks = append(chunks, b.String())
+			b.Reset()
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+	}
+	if b.Len() > 0 {
+		chunks = append(chunks, b.String())
+	}
+	return chunks
+}
+
+// postJSON 返回响应内容，非2xx的响应返回错误，例如已撤销的webhook
+func postJSON(endpoint string, body []byte) ([]byte, error) {
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.Post(endpoint, contentType, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("notify response", "status", resp.Status, "body", string(data))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("notify responded %s: %s", resp.Status, data)
+	}
+	return data, nil
+}
+
 type DMessage struct {
-	MsgType string  `json:"msgtype"`
-	Text    Content `json:"text"`
-	At      At      `json:"at"`
-	IsAtAll bool    `json:"isAtAll"`
+	MsgType  string    `json:"msgtype"`
+	Text     *Content  `json:"text,omitempty"`
+	Markdown *Markdown `json:"markdown,omitempty"`
+	At       At        `json:"at"`
+	IsAtAll  bool      `json:"isAtAll"`
 }
 
 type Content struct {
 	Content string `json:"content"`
 }
 
+type Markdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
 type At struct {
 	AtMobiles []string `json:"atMobiles"`
 }
@@ -89,12 +459,24 @@ func newDMessage(msg string, atMobiles []string, atAll bool) *DMessage {
 	text := Content{Content: msg}
 	return &DMessage{
 		MsgType: "text",
-		Text:    text,
+		Text:    &text,
 		At:      atUsers,
 		IsAtAll: atAll,
 	}
 }
 
+func newDMarkdownMessage(title, msg string, atMobiles []string, atAll bool) *DMessage {
+	if atMobiles == nil {
+		atMobiles = make([]string, 0)
+	}
+	return &DMessage{
+		MsgType:  "markdown",
+		Markdown: &Markdown{Title: title, Text: msg},
+		At:       At{AtMobiles: atMobiles},
+		IsAtAll:  atAll,
+	}
+}
+
 func (tm *DMessage) Encode() []byte {
 	data, err := json.Marshal(&tm)
 	if err != nil {
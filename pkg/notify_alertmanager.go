@@ -0,0 +1,222 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	alertmanagerAlertName = "SSLCertificateCheck"
+	// defaultResolveAfter 告警的endsAt，超过该时间没有再次发送时Alertmanager自动恢复
+	defaultResolveAfter = 48 * time.Hour
+)
+
+// AlertmanagerNotify 以Alertmanager的告警格式POST到url，例如http://alertmanager:9093/api/v2/alerts。
+// 同一host同一Severity的告警合并为一条。Alertmanager在endsAt之后自动恢复告警，所以每轮结束时必须重新发送
+// 本轮仍在告警的全部告警以刷新endsAt，包括被alertState抑制的重复告警；恢复则只发送上一轮告警而本轮不再出现的
+type AlertmanagerNotify struct {
+	ch           <-chan CheckResult
+	url          string
+	headers      map[string]string
+	resolveAfter time.Duration
+	alerting     map[string]map[Severity]bool // 上一轮结束时各host告警中的Severity，跨轮次保留
+}
+
+func newAlertmanagerNotify(config *NotifyConfig, in <-chan CheckResult) *AlertmanagerNotify {
+	resolveAfter := defaultResolveAfter
+	// 格式由Config.Validate检查
+	if d, err := parseResolveAfter(config.GetDefault("resolveAfter", "")); err == nil {
+		resolveAfter = d
+	}
+	return &AlertmanagerNotify{
+		ch:           in,
+		url:          config.Get("url"),
+		headers:      config.GetMap("headers"),
+		resolveAfter: resolveAfter,
+		alerting:     make(map[string]map[Severity]bool),
+	}
+}
+
+func parseResolveAfter(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("resolveAfter %q must be a positive duration", v)
+	}
+	return d, nil
+}
+
+// validateAlertmanagerOptions 检查可选的resolveAfter
+func validateAlertmanagerOptions(values map[string]any) []string {
+	if v, ok := optionalKey(values, "resolveAfter"); ok {
+		if _, err := parseResolveAfter(v); err != nil {
+			return []string{err.Error()}
+		}
+	}
+	return nil
+}
+
+type AlertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+var alertmanagerSeverities = []Severity{SeverityInfo, SeverityWarning, SeverityCritical}
+
+func alertmanagerLabels(host string, severity Severity) map[string]string {
+	return map[string]string{"alertname": alertmanagerAlertName, "host": host, "severity": severity.String()}
+}
+
+// resolvedAlerts 标签完全相同的告警才会被恢复，恢复host在previous中而不在firing中的Severity的告警，
+// previous为nil时恢复除firing以外所有Severity的告警
+func resolvedAlerts(host string, previous, firing map[Severity]bool, now time.Time) []AlertmanagerAlert {
+	alerts := make([]AlertmanagerAlert, 0)
+	for _, severity := range alertmanagerSeverities {
+		if firing[severity] || (previous != nil && !previous[severity]) {
+			continue
+		}
+		alerts = append(alerts, AlertmanagerAlert{
+			Labels:      alertmanagerLabels(host, severity),
+			Annotations: map[string]string{"summary": host + ": " + msgRecovered},
+			StartsAt:    now,
+			EndsAt:      now,
+		})
+	}
+	return alerts
+}
+
+// alertmanagerAlerts 汇总通知没有host，不发送给Alertmanager；恢复通知不知道之前的Severity，全部恢复
+func alertmanagerAlerts(results []CheckResult, now time.Time, resolveAfter time.Duration) []AlertmanagerAlert {
+	alerts := make([]AlertmanagerAlert, 0)
+	index := make(map[string]int)
+	for _, res := range results {
+		if IsSummary(res) {
+			continue
+		}
+		if IsRecovered(res) {
+			alerts = append(alerts, resolvedAlerts(res.Host, nil, nil, now)...)
+			continue
+		}
+		key := res.Host + "\x00" + res.Severity.String()
+		if i, ok := index[key]; ok {
+			alerts[i].Annotations["summary"] += "; " + res.WarnMsg
+			continue
+		}
+		index[key] = len(alerts)
+		alerts = append(alerts, AlertmanagerAlert{
+			Labels:      alertmanagerLabels(res.Host, res.Severity),
+			Annotations: map[string]string{"summary": res.Host + ": " + res.WarnMsg},
+			StartsAt:    now,
+			EndsAt:      now.Add(resolveAfter),
+		})
+	}
+	return alerts
+}
+
+// cycleAlerts 一轮的全部告警，previous中本轮不再出现的Severity被恢复，例如host由warning变为critical时恢复warning。
+// 重启后previous为空，之前的告警不会立即恢复，在endsAt之后由Alertmanager恢复。返回的firing为本轮结束时告警中的Severity
+func cycleAlerts(results []CheckResult, previous map[string]map[Severity]bool, now time.Time, resolveAfter time.Duration) (alerts []AlertmanagerAlert, firing map[string]map[Severity]bool) {
+	alerts = alertmanagerAlerts(results, now, resolveAfter)
+	firing = make(map[string]map[Severity]bool)
+	resolved := make(map[string]bool)
+	for _, res := range results {
+		switch {
+		case IsSummary(res):
+		case IsRecovered(res):
+			resolved[res.Host] = true
+		default:
+			if firing[res.Host] == nil {
+				firing[res.Host] = make(map[Severity]bool)
+			}
+			firing[res.Host][res.Severity] = true
+		}
+	}
+	for host, severities := range previous {
+		// 恢复通知已经恢复了全部Severity
+		if !resolved[host] {
+			alerts = append(alerts, resolvedAlerts(host, severities, firing[host], now)...)
+		}
+	}
+	return alerts, firing
+}
+
+func (an *AlertmanagerNotify) Send(ctx context.Context, waitTime time.Duration) {
+	ticker := time.NewTicker(waitTime)
+	defer ticker.Stop()
+	pending := make([]CheckResult, 0) // 等待发送的新告警
+	cycle := make([]CheckResult, 0)   // 本轮的全部告警，包括被alertState抑制的重复告警
+	for {
+		select {
+		case res := <-an.ch:
+			if isMarker(res) {
+				// 标记与结果按同一顺序到达，收到时本轮的结果都已收到
+				alerts, firing := cycleAlerts(cycle, an.alerting, time.Now().UTC(), an.resolveAfter)
+				if an.send(alerts) {
+					an.alerting = firing
+				}
+				pending, cycle = make([]CheckResult, 0), make([]CheckResult, 0)
+				continue
+			}
+			cycle = append(cycle, res)
+			if !isRepeated(res) {
+				pending = append(pending, res)
+			}
+		case <-ticker.C:
+			if len(pending) > 0 {
+				an.send(alertmanagerAlerts(pending, time.Now().UTC(), an.resolveAfter))
+				pending = make([]CheckResult, 0)
+			}
+		case <-ctx.Done():
+			if len(pending) > 0 {
+				an.send(alertmanagerAlerts(pending, time.Now().UTC(), an.resolveAfter))
+			}
+			return
+		}
+	}
+}
+
+// send 没有告警时不发送，返回是否发送成功
+func (an *AlertmanagerNotify) send(alerts []AlertmanagerAlert) bool {
+	if len(alerts) == 0 {
+		return true
+	}
+	data, err := json.Marshal(alerts)
+	if err == nil {
+		err = an.post(data)
+	}
+	if err != nil {
+		notifyFailed("alertmanager", err)
+		return false
+	}
+	notifySent("alertmanager")
+	return true
+}
+
+func (an *AlertmanagerNotify) post(data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, an.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range an.headers {
+		req.Header.Set(k, v)
+	}
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("alertmanager responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
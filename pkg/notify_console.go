@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// ConsoleNotify 把结果以表格形式输出到标准输出，用于本地调试，不会发送任何请求
+type ConsoleNotify struct {
+	ch  <-chan CheckResult
+	out io.Writer
+}
+
+func newConsoleNotify(in <-chan CheckResult) *ConsoleNotify {
+	return &ConsoleNotify{ch: in, out: os.Stdout}
+}
+
+func (cn *ConsoleNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "console", cn.ch, waitTime, batchLimits{}, func(groups []resultGroup) error {
+		return writeResultTable(cn.out, groups)
+	})
+}
+
+func writeResultTable(out io.Writer, groups []resultGroup) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tWARNING\tDAYS\tNOT AFTER\tSUBJECT\tISSUER")
+	for _, group := range groups {
+		for _, res := range group.Results {
+			days, notAfter := "-", "-"
+			if !res.NotAfter.IsZero() {
+				days = fmt.Sprint(res.DaysRemaining)
+				notAfter = res.NotAfter.Format(time.DateTime)
+			}
+			subject, issuer := res.Subject, res.Issuer
+			if subject == "" {
+				subject = "-"
+			}
+			if issuer == "" {
+				issuer = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", res.Host, res.WarnMsg, days, notAfter, subject, issuer)
+		}
+	}
+	return tw.Flush()
+}
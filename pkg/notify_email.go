@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	emailSubject  = "SSL certificate check warnings"
+	submitPort    = "587"
+	emailMimeHead = "MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n"
+	// emailTimeout 一次发送从连接到QUIT的总时间，避免接受连接后不再响应的服务器阻塞通知
+	emailTimeout = 30 * time.Second
+)
+
+type EmailNotify struct {
+	ch       <-chan CheckResult
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+	to       []string
+	timeout  time.Duration
+}
+
+func newEmailNotify(config *NotifyConfig, in <-chan CheckResult) *EmailNotify {
+	to := make([]string, 0)
+	for _, addr := range strings.Split(config.Get("to"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return &EmailNotify{
+		ch:       in,
+		host:     config.Get("smtpHost"),
+		port:     config.Get("smtpPort"),
+		username: config.Get("username"),
+		password: config.Get("password"),
+		from:     config.Get("from"),
+		to:       to,
+		timeout:  emailTimeout,
+	}
+}
+
+func (en *EmailNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "email", en.ch, waitTime, batchLimits{}, func(groups []resultGroup) error {
+		if err := en.sendMail(emailHTML(groups)); err != nil {
+			return err
+		}
+		slog.Debug("email sent", "to", strings.Join(en.to, ","))
+		return nil
+	})
+}
+
+func (en *EmailNotify) sendMail(body string) error {
+	dialer := net.Dialer{Timeout: defaultTimeout}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(en.host, en.port))
+	if err != nil {
+		return err
+	}
+	if err = conn.SetDeadline(time.Now().Add(en.timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, en.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if en.port == submitPort {
+		if err = c.StartTLS(&tls.Config{ServerName: en.host}); err != nil {
+			return err
+		}
+	}
+	if en.username != "" {
+		if err = c.Auth(smtp.PlainAuth("", en.username, en.password, en.host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(en.from); err != nil {
+		return err
+	}
+	for _, addr := range en.to {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n%s\r\n%s",
+		en.from, strings.Join(en.to, ","), emailSubject, emailMimeHead, body)
+	if _, err = w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailHTML 每种告警一张表格，列出对应的host
+func emailHTML(groups []resultGroup) string {
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	for _, group := range groups {
+		b.WriteString(fmt.Sprintf("<h3>%s</h3><table border=\"1\" cellpadding=\"4\">", html.EscapeString(group.WarnMsg)))
+		b.WriteString("<tr><th>Host</th></tr>")
+		for _, host := range group.Hosts {
+			b.WriteString(fmt.Sprintf("<tr><td>%s</td></tr>", html.EscapeString(host)))
+		}
+		b.WriteString("</table>")
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// matrixMaxBytes Matrix事件最大65536字节，正文和formatted_body各占一半左右
+const matrixMaxBytes = 30000
+
+// MatrixNotify 通过Client-Server API向房间发送m.room.message
+type MatrixNotify struct {
+	batchLimits
+	ch          <-chan CheckResult
+	homeserver  string
+	accessToken string
+	roomID      string
+	txnSeq      atomic.Int64
+}
+
+func newMatrixNotify(config *NotifyConfig, in <-chan CheckResult) *MatrixNotify {
+	return &MatrixNotify{
+		ch:          in,
+		homeserver:  strings.TrimRight(config.Get("homeserverUrl"), "/"),
+		accessToken: config.Get("accessToken"),
+		roomID:      config.Get("roomId"),
+	}
+}
+
+type MatrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+func (mn *MatrixNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "matrix", mn.ch, waitTime, mn.batchLimits, func(groups []resultGroup) error {
+		for _, chunk := range matrixChunks(groups) {
+			if err := mn.post(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// txnID 同一个access token下事务id需要唯一，homeserver按事务id对重试去重
+func (mn *MatrixNotify) txnID(now time.Time) string {
+	return "check-certs-" + strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatInt(mn.txnSeq.Add(1), 36)
+}
+
+func (mn *MatrixNotify) post(msg MatrixMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		mn.homeserver, url.PathEscape(mn.roomID), url.PathEscape(mn.txnID(time.Now())))
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+mn.accessToken)
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("matrix responded %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// matrixChunks 每种告警一个标题，下面列出对应的host，超过长度限制时拆分为多条消息
+func matrixChunks(groups []resultGroup) []MatrixMessage {
+	msgs := make([]MatrixMessage, 0)
+	var text, formatted strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			msgs = append(msgs, MatrixMessage{
+				MsgType:       "m.text",
+				Body:          strings.TrimRight(text.String(), "\n"),
+				Format:        "org.matrix.custom.html",
+				FormattedBody: formatted.String(),
+			})
+			text.Reset()
+			formatted.Reset()
+		}
+	}
+	for _, group := range groups {
+		title := html.EscapeString(group.WarnMsg)
+		if formatted.Len()+len(title) > matrixMaxBytes {
+			flush()
+		}
+		text.WriteString(group.WarnMsg + "\n")
+		formatted.WriteString("<b>" + title + "</b><ul>")
+		for _, host := range group.Hosts {
+			item := "<li>" + html.EscapeString(host) + "</li>"
+			if formatted.Len()+len(item) > matrixMaxBytes {
+				formatted.WriteString("</ul>")
+				flush()
+				text.WriteString(group.WarnMsg + "\n")
+				formatted.WriteString("<b>" + title + "</b><ul>")
+			}
+			text.WriteString("- " + host + "\n")
+			formatted.WriteString(item)
+		}
+		formatted.WriteString("</ul>")
+	}
+	flush()
+	return msgs
+}
@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	pagerDutyURL          = "https://events.pagerduty.com/v2/enqueue"
+	pagerDutyCriticalDays = 7
+	pagerDutyDedupPrefix  = "check-certs/"
+)
+
+// PagerDutyNotify 对已过期或即将过期的host触发PagerDuty事件，host恢复后自动resolve。
+// dedup_key由host生成，重复触发只会更新同一个incident
+type PagerDutyNotify struct {
+	ch           <-chan CheckResult
+	url          string
+	routingKey   string
+	criticalDays int
+	alerting     map[string]bool // 已触发且尚未resolve的host，跨轮次保留
+}
+
+func newPagerDutyNotify(config *NotifyConfig, in <-chan CheckResult) *PagerDutyNotify {
+	// 格式由Config.Validate检查
+	criticalDays, err := parseCriticalDays(config.GetDefault("criticalDays", ""))
+	if err != nil {
+		criticalDays = pagerDutyCriticalDays
+	}
+	return &PagerDutyNotify{
+		ch:           in,
+		url:          config.GetDefault("url", pagerDutyURL),
+		routingKey:   config.Get("routingKey"),
+		criticalDays: criticalDays,
+		alerting:     make(map[string]bool),
+	}
+}
+
+func parseCriticalDays(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("criticalDays %q must be a number", v)
+	}
+	return n, nil
+}
+
+// validatePagerDutyOptions 检查可选的criticalDays
+func validatePagerDutyOptions(values map[string]any) []string {
+	if v, ok := optionalKey(values, "criticalDays"); ok {
+		if _, err := parseCriticalDays(v); err != nil {
+			return []string{err.Error()}
+		}
+	}
+	return nil
+}
+
+type PagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *PagerDutyPayload `json:"payload,omitempty"`
+}
+
+type PagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+// critical 只有已过期或剩余天数不超过criticalDays的即将过期告警才需要呼叫值班，公钥、证书链等其他告警不呼叫
+func (pn *PagerDutyNotify) critical(res CheckResult) bool {
+	if res.WarnMsg == errExpired {
+		return true
+	}
+	return slices.Contains(expiringKinds, alertKind(res.WarnMsg)) && res.DaysRemaining <= pn.criticalDays
+}
+
+func (pn *PagerDutyNotify) Send(ctx context.Context, waitTime time.Duration) {
+	ticker := time.NewTicker(waitTime)
+	defer ticker.Stop()
+	pending := make(map[string]CheckResult) // 等待发送的trigger
+	current := make(map[string]bool)        // 本轮检查中处于告警状态的host
+	checked := make(map[string]bool)        // 本轮检查中有结果的host
+	for {
+		select {
+		case res := <-pn.ch:
+			// 标记与结果按同一顺序到达，收到时本轮的结果都已收到。
+			// 只resolve本轮有结果但不再需要呼叫的host，连接失败或provider失败没有结果的host不能当作已恢复
+			if isMarker(res) {
+				pn.trigger(pending)
+				for host := range pn.alerting {
+					if checked[host] && !current[host] {
+						pn.resolve(host)
+					}
+				}
+				current, checked = make(map[string]bool), make(map[string]bool)
+				continue
+			}
+			// 没有任何告警的host只能通过alertState的恢复通知得知，重启后alerting可能不完整，收到时总是resolve
+			if IsRecovered(res) {
+				delete(pending, res.Host)
+				pn.resolve(res.Host)
+				continue
+			}
+			checked[res.Host] = true
+			if !pn.critical(res) {
+				continue
+			}
+			current[res.Host] = true
+			// 被alertState抑制的告警已经触发过，重启或重载后alerting为空，同样记为告警中，恢复后才能resolve
+			if isRepeated(res) {
+				pn.alerting[res.Host] = true
+			} else {
+				pending[res.Host] = res
+			}
+		case <-ticker.C:
+			pn.trigger(pending)
+		case <-ctx.Done():
+			pn.trigger(pending)
+			return
+		}
+	}
+}
+
+// trigger 发送成功的host从pending中删除，失败的留到下次重试
+func (pn *PagerDutyNotify) trigger(pending map[string]CheckResult) {
+	for host, res := range pending {
+		event := PagerDutyEvent{
+			RoutingKey:  pn.routingKey,
+			EventAction: "trigger",
+			DedupKey:    pagerDutyDedupPrefix + host,
+			Payload: &PagerDutyPayload{
+				Summary:  fmt.Sprintf("%s: %s", host, res.WarnMsg),
+				Source:   host,
+				Severity: "critical",
+				CustomDetails: map[string]any{
+					"daysRemaining": res.DaysRemaining,
+					"notAfter":      res.NotAfter,
+					"issuer":        res.Issuer,
+					"subject":       res.Subject,
+				},
+			},
+		}
+		if err := pn.post(event); err != nil {
+			notifyFailed("pagerduty", err, "host", host)
+			continue
+		}
+		notifySent("pagerduty")
+		pn.alerting[host] = true
+		delete(pending, host)
+	}
+}
+
+func (pn *PagerDutyNotify) resolve(host string) {
+	event := PagerDutyEvent{RoutingKey: pn.routingKey, EventAction: "resolve", DedupKey: pagerDutyDedupPrefix + host}
+	if err := pn.post(event); err != nil {
+		notifyFailed("pagerduty", err, "host", host)
+		return
+	}
+	notifySent("pagerduty")
+	slog.Info("pagerduty incident resolved", "host", host)
+	delete(pn.alerting, host)
+}
+
+func (pn *PagerDutyNotify) post(event PagerDutyEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.Post(pn.url, contentType, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("pagerduty responded %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pushTitle        = "SSL certificate check"
+	pushoverURL      = "https://api.pushover.net/1/messages.json"
+	pushoverMaxBytes = 1024
+	gotifyMaxBytes   = 60000
+	barkURL          = "https://api.day.app"
+	// barkMaxBytes 消息放在URL路径中，编码后过长会被服务端或代理拒绝
+	barkMaxBytes       = 2000
+	serverChanURL      = "https://sctapi.ftqq.com"
+	serverChanMaxBytes = 32000
+)
+
+// maxSeverity 一批结果中最严重的等级，决定推送的优先级
+func maxSeverity(groups []resultGroup) Severity {
+	severity := SeverityInfo
+	for _, group := range groups {
+		for _, res := range group.Results {
+			severity = max(severity, res.Severity)
+		}
+	}
+	return severity
+}
+
+// pushLines 每种告警一行标题，下面列出对应的host
+func pushLines(groups []resultGroup) []string {
+	lines := make([]string, 0)
+	for _, group := range groups {
+		lines = append(lines, group.WarnMsg)
+		for _, host := range group.Hosts {
+			lines = append(lines, "- "+host)
+		}
+	}
+	return lines
+}
+
+// gotifyPriority Gotify客户端默认对优先级8及以上弹出通知并响铃，4到7只弹出通知
+func gotifyPriority(severity Severity) int {
+	switch severity {
+	case SeverityCritical:
+		return 8
+	case SeverityWarning:
+		return 5
+	default:
+		return 2
+	}
+}
+
+// GotifyNotify 向自建的Gotify服务推送消息，优先级由结果中最高的Severity决定
+type GotifyNotify struct {
+	batchLimits
+	ch       <-chan CheckResult
+	url      string
+	appToken string
+}
+
+func newGotifyNotify(config *NotifyConfig, in <-chan CheckResult) *GotifyNotify {
+	return &GotifyNotify{
+		ch:       in,
+		url:      strings.TrimRight(config.Get("serverUrl"), "/") + "/message",
+		appToken: config.Get("appToken"),
+	}
+}
+
+type GotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+func (gn *GotifyNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "gotify", gn.ch, waitTime, gn.batchLimits, func(groups []resultGroup) error {
+		priority := gotifyPriority(maxSeverity(groups))
+		for _, chunk := range chunkLines(pushLines(groups), gotifyMaxBytes) {
+			if err := gn.post(GotifyMessage{Title: pushTitle, Message: chunk, Priority: priority}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (gn *GotifyNotify) post(msg GotifyMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, gn.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Gotify-Key", gn.appToken)
+	return doPush("gotify", req)
+}
+
+// PushoverNotify 通过Pushover推送消息，单条消息最多1024个字符，超长时拆分为多条
+type PushoverNotify struct {
+	batchLimits
+	ch    <-chan CheckResult
+	url   string
+	token string
+	user  string
+}
+
+func newPushoverNotify(config *NotifyConfig, in <-chan CheckResult) *PushoverNotify {
+	return &PushoverNotify{
+		ch:    in,
+		url:   config.GetDefault("url", pushoverURL),
+		token: config.Get("appToken"),
+		user:  config.Get("userKey"),
+	}
+}
+
+// pushoverPriority 严重告警使用高优先级绕过免打扰，仅有建议时静默推送。
+// 不使用需要确认的紧急优先级2，它要求额外的retry和expire参数
+func pushoverPriority(severity Severity) int {
+	switch severity {
+	case SeverityCritical:
+		return 1
+	case SeverityWarning:
+		return 0
+	default:
+		return -1
+	}
+}
+
+func (pn *PushoverNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "pushover", pn.ch, waitTime, pn.batchLimits, func(groups []resultGroup) error {
+		priority := pushoverPriority(maxSeverity(groups))
+		for _, chunk := range chunkLines(pushLines(groups), pushoverMaxBytes) {
+			if err := pn.post(chunk, priority); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pn *PushoverNotify) post(message string, priority int) error {
+	form := url.Values{
+		"token":    {pn.token},
+		"user":     {pn.user},
+		"title":    {pushTitle},
+		"message":  {message},
+		"priority": {strconv.Itoa(priority)},
+	}
+	req, err := http.NewRequest(http.MethodPost, pn.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doPush("pushover", req)
+}
+
+// barkLevel 严重告警使用timeSensitive突破专注模式，仅有建议时静默推送
+func barkLevel(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return "timeSensitive"
+	case SeverityWarning:
+		return "active"
+	default:
+		return "passive"
+	}
+}
+
+// BarkNotify 通过Bark推送到iOS设备，serverUrl默认为官方服务器，也可以是自建的bark-server
+type BarkNotify struct {
+	batchLimits
+	ch        <-chan CheckResult
+	url       string
+	deviceKey string
+}
+
+func newBarkNotify(config *NotifyConfig, in <-chan CheckResult) *BarkNotify {
+	return &BarkNotify{
+		ch:        in,
+		url:       strings.TrimRight(config.GetDefault("serverUrl", barkURL), "/"),
+		deviceKey: config.Get("deviceKey"),
+	}
+}
+
+func (bn *BarkNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "bark", bn.ch, waitTime, bn.batchLimits, func(groups []resultGroup) error {
+		level := barkLevel(maxSeverity(groups))
+		for _, chunk := range chunkLines(pushLines(groups), barkMaxBytes) {
+			if err := bn.post(chunk, level); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// post 请求GET /{deviceKey}/{title}/{body}，标题和内容按路径编码
+func (bn *BarkNotify) post(message, level string) error {
+	endpoint := fmt.Sprintf("%s/%s/%s/%s?%s", bn.url, url.PathEscape(bn.deviceKey), url.PathEscape(pushTitle),
+		url.PathEscape(message), url.Values{"level": {level}}.Encode())
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return doPush("bark", req)
+}
+
+// ServerChanNotify 通过Server酱推送到微信，desp按Markdown展示
+type ServerChanNotify struct {
+	batchLimits
+	ch  <-chan CheckResult
+	url string
+}
+
+func newServerChanNotify(config *NotifyConfig, in <-chan CheckResult) *ServerChanNotify {
+	return &ServerChanNotify{
+		ch:  in,
+		url: fmt.Sprintf("%s/%s.send", strings.TrimRight(config.GetDefault("url", serverChanURL), "/"), url.PathEscape(config.Get("sendKey"))),
+	}
+}
+
+// ServerChanResponse 请求失败时HTTP状态码也可能是200，code不为0表示失败
+type ServerChanResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (sn *ServerChanNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "serverchan", sn.ch, waitTime, sn.batchLimits, func(groups []resultGroup) error {
+		for _, chunk := range chunkLines(pushLines(groups), serverChanMaxBytes) {
+			if err := sn.post(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (sn *ServerChanNotify) post(message string) error {
+	form := url.Values{"title": {pushTitle}, "desp": {message}}
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.PostForm(sn.url, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var sr ServerChanResponse
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &sr) != nil || sr.Code != 0 {
+		return fmt.Errorf("serverchan responded %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+func doPush(name string, req *http.Request) error {
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s responded %s: %s", name, resp.Status, body)
+	}
+	return nil
+}
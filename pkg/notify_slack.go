@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type SlackNotify struct {
+	batchLimits
+	ch  <-chan CheckResult
+	url string
+}
+
+type SlackMessage struct {
+	Text string `json:"text"`
+}
+
+func (sn *SlackNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "slack", sn.ch, waitTime, sn.batchLimits, func(groups []resultGroup) error {
+		data, err := json.Marshal(SlackMessage{Text: slackText(groups)})
+		if err != nil {
+			return err
+		}
+		_, err = postJSON(sn.url, data)
+		return err
+	})
+}
+
+// slackText 每种告警一个加粗标题，下面列出对应的host
+func slackText(groups []resultGroup) string {
+	sections := make([]string, 0, len(groups))
+	for _, group := range groups {
+		lines := []string{fmt.Sprintf("*%s*", group.WarnMsg)}
+		for _, host := range group.Hosts {
+			lines = append(lines, "• "+host)
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	return strings.Join(sections, "\n\n")
+}
@@ -0,0 +1,643 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDDingSign(t *testing.T) {
+	sign := ddingSign("1577262236757", "SEC1234567890abcdef")
+	if sign != "f7qQ1A8KagEZ45y1gasTtKsEq5ERTeDXP9T+WkIqzDk=" {
+		t.Errorf("unexpected sign %s", sign)
+	}
+	dn := &DDingNotify{url: "https://oapi.dingtalk.com/robot/send?access_token=abc", secret: "SEC1234567890abcdef"}
+	got := dn.signedURL(time.UnixMilli(1577262236757))
+	want := "https://oapi.dingtalk.com/robot/send?access_token=abc&sign=f7qQ1A8KagEZ45y1gasTtKsEq5ERTeDXP9T%2BWkIqzDk%3D&timestamp=1577262236757"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	dn.secret = ""
+	if dn.signedURL(time.Now()) != dn.url {
+		t.Error("url should be unchanged without secret")
+	}
+}
+
+func TestChunkLines(t *testing.T) {
+	chunks := chunkLines([]string{"aaaa", "bbbb", "cccc", "dddddddddddd"}, 10)
+	want := []string{"aaaa\nbbbb", "cccc", "dddddddddddd"}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %q, want %q", chunks, want)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("chunk %d: got %q, want %q", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestCollect_FlushOnCancel(t *testing.T) {
+	ch := make(chan CheckResult)
+	ctx, cancel := context.WithCancel(context.Background())
+	sent := make(chan []resultGroup, 1)
+	done := make(chan struct{})
+	go func() {
+		collect(ctx, "test", ch, time.Hour, batchLimits{}, func(groups []resultGroup) error {
+			sent <- groups
+			return nil
+		})
+		close(done)
+	}()
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: errExpired}
+	ch <- CheckResult{Host: "b.com:443", WarnMsg: errExpired}
+	cancel()
+	<-done
+	select {
+	case groups := <-sent:
+		if len(groups) != 1 || len(groups[0].Hosts) != 2 {
+			t.Errorf("unexpected groups %+v", groups)
+		}
+	default:
+		t.Fatal("buffered results were not flushed on cancel")
+	}
+}
+
+func TestCollect_Flush(t *testing.T) {
+	ch := make(chan CheckResult)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sent := make(chan []resultGroup, 10)
+	go collect(ctx, "flush-test", ch, time.Hour, batchLimits{}, func(groups []resultGroup) error {
+		sent <- groups
+		return nil
+	})
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: errExpired}
+	ch <- CycleEnd(true)
+	// 没有新的结果时再次flush不发送
+	ch <- CycleEnd(true)
+	select {
+	case groups := <-sent:
+		if len(groups) != 1 || len(groups[0].Hosts) != 1 {
+			t.Errorf("unexpected groups %+v", groups)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("flush did not send the buffered results")
+	}
+	select {
+	case groups := <-sent:
+		t.Errorf("results sent twice: %+v", groups)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCollect_BatchLimits(t *testing.T) {
+	ch := make(chan CheckResult)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sent := make(chan []resultGroup, 10)
+	go collect(ctx, "batch-test", ch, 50*time.Millisecond, batchLimits{maxHosts: 2, maxMessages: 2}, func(groups []resultGroup) error {
+		sent <- groups
+		return nil
+	})
+	for _, host := range []string{"a.com:443", "b.com:443", "c.com:443"} {
+		ch <- CheckResult{Host: host, WarnMsg: errExpired}
+	}
+	ch <- CheckResult{Host: "d.com:443", WarnMsg: errHostname}
+	ch <- CheckResult{Host: "e.com:443", WarnMsg: errHostname}
+	hosts := make([]string, 0)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		batch := <-sent
+		count := 0
+		for _, group := range batch {
+			count += len(group.Hosts)
+			hosts = append(hosts, group.Hosts...)
+		}
+		if count > 2 {
+			t.Errorf("batch %d has %d hosts", i, count)
+		}
+		// 第三批超过maxMessages，留到下一次flush
+		if i == 2 && time.Since(start) < 75*time.Millisecond {
+			t.Error("third batch was sent in the first flush")
+		}
+	}
+	if strings.Join(hosts, ",") != "a.com:443,b.com:443,c.com:443,d.com:443,e.com:443" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if limits := parseBatchLimits(&NotifyConfig{Type: "dding", Config: map[string]any{"maxHosts": "10"}}); limits != (batchLimits{maxHosts: 10, maxMessages: 20}) {
+		t.Errorf("unexpected limits %+v", limits)
+	}
+}
+
+func TestCollect_Metrics(t *testing.T) {
+	ch := make(chan CheckResult)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failures := testutil.ToFloat64(notificationFailures.WithLabelValues("metrics-test"))
+	sent := testutil.ToFloat64(notificationsSent.WithLabelValues("metrics-test"))
+	fail := make(chan bool, 1)
+	done := make(chan struct{}, 1)
+	go collect(ctx, "metrics-test", ch, 10*time.Millisecond, batchLimits{}, func(groups []resultGroup) error {
+		defer func() { done <- struct{}{} }()
+		if <-fail {
+			return errors.New("webhook returned 404")
+		}
+		return nil
+	})
+	fail <- true
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: errExpired}
+	<-done
+	fail <- false
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: errExpired}
+	<-done
+	if got := testutil.ToFloat64(notificationFailures.WithLabelValues("metrics-test")) - failures; got != 1 {
+		t.Errorf("expected 1 failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(notificationsSent.WithLabelValues("metrics-test")) - sent; got != 1 {
+		t.Errorf("expected 1 notification sent, got %v", got)
+	}
+	if got := testutil.ToFloat64(notificationLastSuccess.WithLabelValues("metrics-test")); got < float64(time.Now().Add(-time.Minute).Unix()) {
+		t.Errorf("unexpected last success timestamp %v", got)
+	}
+}
+
+func TestDDingNotify_Post(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg DMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		switch msg.Text.Content {
+		case "revoked":
+			w.Write([]byte(`{"errcode":300001,"errmsg":"token is not exist"}`))
+		case "gone":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		}
+	}))
+	defer srv.Close()
+	dn := &DDingNotify{url: srv.URL}
+	if err := dn.post(&DMessage{MsgType: "text", Text: &Content{Content: "a.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dn.post(&DMessage{MsgType: "text", Text: &Content{Content: "revoked"}}); err == nil || !strings.Contains(err.Error(), "300001") {
+		t.Errorf("expected errcode error, got %v", err)
+	}
+	if err := dn.post(&DMessage{MsgType: "text", Text: &Content{Content: "gone"}}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
+
+func TestEmailNotify_SendMailTimeout(t *testing.T) {
+	// 接受连接后不发送问候语的服务器
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	en := &EmailNotify{host: host, port: port, from: "a@example.com", to: []string{"b@example.com"}, timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if err = en.sendMail("body"); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sendMail took %s", elapsed)
+	}
+}
+
+func TestWeComNotify_Post(t *testing.T) {
+	var got WeComMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if got.Markdown.Content == "limited" {
+			w.Write([]byte(`{"errcode":45009,"errmsg":"api freq out of limit"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+	wn := newWeComNotify(srv.URL, nil)
+	if err := wn.post("**expired**\n> a.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got.MsgType != "markdown" || got.Markdown.Content != "**expired**\n> a.com" {
+		t.Errorf("unexpected message %+v", got)
+	}
+	if err := wn.post("limited"); err == nil || !strings.Contains(err.Error(), "45009") {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+}
+
+func TestWebhookNotify_Post(t *testing.T) {
+	var got WebhookPayload
+	var method, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, auth = r.Method, r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	wn := &WebhookNotify{url: srv.URL, method: http.MethodPut, contentType: contentType, headers: map[string]string{"Authorization": "Bearer t"}}
+	groups := []resultGroup{{
+		WarnMsg: errExpired,
+		Hosts:   []string{"a.com:443"},
+		Results: []CheckResult{{WarnMsg: errExpired, Host: "a.com:443", DaysRemaining: -2}},
+	}}
+	data, _ := json.Marshal(webhookPayload(groups, time.Unix(0, 0)))
+	if err := wn.post(data); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || auth != "Bearer t" {
+		t.Errorf("unexpected request %s %q", method, auth)
+	}
+	if len(got.Results) != 1 || got.Results[0] != (WebhookResult{Host: "a.com:443", WarnMsg: errExpired, DaysRemaining: -2}) || !got.Timestamp.Equal(time.Unix(0, 0)) {
+		t.Errorf("unexpected payload %+v", got)
+	}
+}
+
+func TestHostLabel(t *testing.T) {
+	if got := hostLabel(CheckResult{Host: "a.com:443"}); got != "a.com:443" {
+		t.Errorf("unexpected label %q", got)
+	}
+	if got := hostLabel(CheckResult{Host: "a.com:443", Issuer: "CN=R3,O=Let's Encrypt,C=US"}); got != "a.com:443 (issuer: CN=R3,O=Let's Encrypt,C=US)" {
+		t.Errorf("unexpected label %q", got)
+	}
+	if got := hostLabel(CheckResult{Host: "a.com:443", Subject: "CN=shared.example.com", Issuer: "CN=R3"}); got != "a.com:443 (subject: CN=shared.example.com, issuer: CN=R3)" {
+		t.Errorf("unexpected label %q", got)
+	}
+}
+
+func TestWriteResultTable(t *testing.T) {
+	notAfter := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	groups := []resultGroup{
+		{WarnMsg: errExpired, Results: []CheckResult{{WarnMsg: errExpired, Host: "a.com:443", DaysRemaining: -1, NotAfter: notAfter, Issuer: "CN=R3", Subject: "CN=a.com"}}},
+		{WarnMsg: errConnRefused, Results: []CheckResult{{WarnMsg: errConnRefused, Host: "b.com:443"}}},
+	}
+	var b strings.Builder
+	if err := writeResultTable(&b, groups); err != nil {
+		t.Fatal(err)
+	}
+	want := `HOST       WARNING                     DAYS  NOT AFTER            SUBJECT   ISSUER
+a.com:443  SSLCertificate has expired  -1    2026-01-02 03:04:05  CN=a.com  CN=R3
+b.com:443  connection refused          -     -                    -         -
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestPagerDutyNotify_TriggerAndResolve(t *testing.T) {
+	events := make(chan PagerDutyEvent, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event PagerDutyEvent
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	ch := make(chan CheckResult)
+	pn := newPagerDutyNotify(&NotifyConfig{Type: "pagerduty", Config: map[string]any{"routingKey": "key", "url": srv.URL}}, ch)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pn.Send(ctx, time.Hour)
+	}()
+	notAfter := time.Now().Add(48 * time.Hour)
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: errExpired}
+	ch <- CheckResult{Host: "b.com:443", WarnMsg: "expires in 2 days", DaysRemaining: 2, NotAfter: notAfter}
+	ch <- CheckResult{Host: "c.com:443", WarnMsg: "expires in 9 days", DaysRemaining: 9, NotAfter: notAfter}
+	// 即将过期以外的证书告警不呼叫
+	ch <- CheckResult{Host: "d.com:443", WarnMsg: "weak key: RSA 1024 bits", DaysRemaining: 2, NotAfter: notAfter}
+	ch <- CycleEnd(false)
+	triggered := map[string]bool{}
+	for range 2 {
+		event := <-events
+		if event.EventAction != "trigger" || event.RoutingKey != "key" || event.Payload == nil {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		triggered[event.DedupKey] = true
+	}
+	if !triggered["check-certs/a.com:443"] || !triggered["check-certs/b.com:443"] {
+		t.Errorf("unexpected triggers %v", triggered)
+	}
+	// 下一轮a.com仍然过期，b.com续期后不再需要呼叫，应被resolve
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: errExpired}
+	ch <- CheckResult{Host: "b.com:443", WarnMsg: "expires in 20 days", DaysRemaining: 20, NotAfter: notAfter}
+	ch <- CycleEnd(false)
+	if event := <-events; event.EventAction != "trigger" || event.DedupKey != "check-certs/a.com:443" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event := <-events; event.EventAction != "resolve" || event.DedupKey != "check-certs/b.com:443" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	// 连接失败或provider失败而没有结果的a.com不resolve，收到恢复通知时才resolve
+	ch <- CycleEnd(false)
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: msgRecovered, Severity: SeverityInfo}
+	ch <- CycleEnd(false)
+	if event := <-events; event.EventAction != "resolve" || event.DedupKey != "check-certs/a.com:443" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	cancel()
+	<-done
+	if len(events) != 0 {
+		t.Errorf("unexpected extra events %d", len(events))
+	}
+
+	// 重启后alertState抑制的告警不会再触发，但host恢复后仍需resolve
+	pn = newPagerDutyNotify(&NotifyConfig{Type: "pagerduty", Config: map[string]any{"routingKey": "key", "url": srv.URL}}, ch)
+	ctx, cancel = context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		pn.Send(ctx, time.Hour)
+	}()
+	ch <- Repeated(CheckResult{Host: "a.com:443", WarnMsg: errExpired})
+	ch <- CycleEnd(false)
+	ch <- CheckResult{Host: "a.com:443", WarnMsg: "expires in 80 days", DaysRemaining: 80, NotAfter: notAfter}
+	ch <- CycleEnd(false)
+	if event := <-events; event.EventAction != "resolve" || event.DedupKey != "check-certs/a.com:443" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	cancel()
+	<-done
+	if len(events) != 0 {
+		t.Errorf("unexpected extra events %d", len(events))
+	}
+}
+
+func TestMatrixNotify_Post(t *testing.T) {
+	var got MatrixMessage
+	paths := make([]string, 0)
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		paths = append(paths, r.URL.EscapedPath())
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer srv.Close()
+	mn := newMatrixNotify(&NotifyConfig{Type: "matrix", Config: map[string]any{
+		"homeserverUrl": srv.URL + "/", "accessToken": "t", "roomId": "!room:example.com",
+	}}, nil)
+	msgs := matrixChunks([]resultGroup{{WarnMsg: errExpired, Hosts: []string{"a.com:443", "<b>.com"}}})
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	for i := 0; i < 2; i++ {
+		if err := mn.post(msgs[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if auth != "Bearer t" || len(paths) != 2 || paths[0] == paths[1] {
+		t.Errorf("unexpected requests %q %v", auth, paths)
+	}
+	if !strings.HasPrefix(paths[0], "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/") {
+		t.Errorf("unexpected path %s", paths[0])
+	}
+	if got.MsgType != "m.text" || got.Body != errExpired+"\n- a.com:443\n- <b>.com" {
+		t.Errorf("unexpected body %+v", got)
+	}
+	if !strings.Contains(got.FormattedBody, "<li>&lt;b&gt;.com</li>") {
+		t.Errorf("host is not escaped: %s", got.FormattedBody)
+	}
+}
+
+func TestGotifyNotify_Priority(t *testing.T) {
+	var got GotifyMessage
+	var path, key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.Header.Get("X-Gotify-Key")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	gn := newGotifyNotify(&NotifyConfig{Type: "gotify", Config: map[string]any{"serverUrl": srv.URL, "appToken": "t"}}, nil)
+	groups := []resultGroup{
+		{WarnMsg: "expiring soon", Hosts: []string{"a.com"}, Results: []CheckResult{{Severity: SeverityWarning}}},
+		{WarnMsg: errExpired, Hosts: []string{"b.com"}, Results: []CheckResult{{Severity: SeverityCritical}}},
+	}
+	msg := GotifyMessage{Title: pushTitle, Message: strings.Join(pushLines(groups), "\n"), Priority: gotifyPriority(maxSeverity(groups))}
+	if err := gn.post(msg); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/message" || key != "t" {
+		t.Errorf("unexpected request %s %q", path, key)
+	}
+	if got.Priority != 8 || got.Message != "expiring soon\n- a.com\n"+errExpired+"\n- b.com" {
+		t.Errorf("unexpected message %+v", got)
+	}
+}
+
+func TestPushoverNotify_Post(t *testing.T) {
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = r.PostForm
+		if r.PostForm.Get("user") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"user":"invalid","status":0}`))
+		}
+	}))
+	defer srv.Close()
+	pn := newPushoverNotify(&NotifyConfig{Type: "pushover", Config: map[string]any{"url": srv.URL, "appToken": "t", "userKey": "u"}}, nil)
+	if err := pn.post("expired", pushoverPriority(SeverityWarning)); err != nil {
+		t.Fatal(err)
+	}
+	if form["token"][0] != "t" || form["user"][0] != "u" || form["message"][0] != "expired" || form["priority"][0] != "0" {
+		t.Errorf("unexpected form %v", form)
+	}
+	pn.user = "bad"
+	if err := pn.post("expired", 0); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+func TestBarkNotify_Post(t *testing.T) {
+	var path, level string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, level = r.URL.EscapedPath(), r.URL.Query().Get("level")
+		w.Write([]byte(`{"code":200,"message":"success"}`))
+	}))
+	defer srv.Close()
+	bn := newBarkNotify(&NotifyConfig{Type: "bark", Config: map[string]any{"serverUrl": srv.URL + "/", "deviceKey": "key"}}, nil)
+	if err := bn.post("expired\n- a.com/b", barkLevel(SeverityCritical)); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/key/SSL%20certificate%20check/expired%0A-%20a.com%2Fb" || level != "timeSensitive" {
+		t.Errorf("unexpected request %s level %q", path, level)
+	}
+}
+
+func TestServerChanNotify_Post(t *testing.T) {
+	var path string
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		path, form = r.URL.Path, r.PostForm
+		if r.PostForm.Get("desp") == "bad" {
+			w.Write([]byte(`{"code":40001,"message":"bad pushkey"}`))
+			return
+		}
+		w.Write([]byte(`{"code":0,"message":"","data":{"pushid":"1"}}`))
+	}))
+	defer srv.Close()
+	sn := newServerChanNotify(&NotifyConfig{Type: "serverchan", Config: map[string]any{"url": srv.URL, "sendKey": "SCT1"}}, nil)
+	if err := sn.post("expired"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/SCT1.send" || form["title"][0] != pushTitle || form["desp"][0] != "expired" {
+		t.Errorf("unexpected request %s %v", path, form)
+	}
+	if err := sn.post("bad"); err == nil || !strings.Contains(err.Error(), "bad pushkey") {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+func TestBroadcast_AllNotifiersReceive(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, res := range payload.Results {
+			received[r.URL.Path] = append(received[r.URL.Path], res.Host)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan CheckResult)
+	outs := make([]chan<- CheckResult, 0)
+	var wg sync.WaitGroup
+	for _, path := range []string{"/a", "/b"} {
+		ch := make(chan CheckResult)
+		outs = append(outs, ch)
+		notify := NewNotify(&NotifyConfig{Type: "webhook", Config: map[string]any{"url": srv.URL + path}}, ch)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			notify.Send(ctx, time.Hour)
+		}()
+	}
+	delivered := make(chan struct{})
+	go func() {
+		defer close(delivered)
+		Broadcast(ctx, in, outs)
+	}()
+	hosts := []string{"a.com:443", "b.com:443", "c.com:443"}
+	for _, host := range hosts {
+		in <- CheckResult{Host: host, WarnMsg: errExpired}
+	}
+	close(in)
+	<-delivered
+	cancel()
+	wg.Wait()
+
+	for _, path := range []string{"/a", "/b"} {
+		if strings.Join(received[path], ",") != strings.Join(hosts, ",") {
+			t.Errorf("notifier %s received %v, want %v", path, received[path], hosts)
+		}
+	}
+}
+
+func TestAlertmanagerAlerts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	results := []CheckResult{
+		{Host: "a.com", WarnMsg: "expires in 5 days", Severity: SeverityWarning},
+		{Host: "a.com", WarnMsg: "weak key: RSA 1024 bits", Severity: SeverityWarning},
+		{Host: "b.com", WarnMsg: errExpired, Severity: SeverityCritical},
+		{Host: "c.com", WarnMsg: msgRecovered, Severity: SeverityInfo},
+		{WarnMsg: "Checked 3 hosts"},
+	}
+	alerts := alertmanagerAlerts(results, now, time.Hour)
+	if len(alerts) != 5 {
+		t.Fatalf("expected 5 alerts, got %+v", alerts)
+	}
+	a := alerts[0]
+	if a.Labels["alertname"] != alertmanagerAlertName || a.Labels["host"] != "a.com" || a.Labels["severity"] != "warning" {
+		t.Errorf("unexpected labels %v", a.Labels)
+	}
+	if a.Annotations["summary"] != "a.com: expires in 5 days; weak key: RSA 1024 bits" || !a.StartsAt.Equal(now) || !a.EndsAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected alert %+v", a)
+	}
+	if alerts[1].Labels["severity"] != "critical" {
+		t.Errorf("unexpected labels %v", alerts[1].Labels)
+	}
+	for _, resolved := range alerts[2:] {
+		if resolved.Labels["host"] != "c.com" || !resolved.EndsAt.Equal(now) {
+			t.Errorf("expected c.com to be resolved, got %+v", resolved)
+		}
+	}
+}
+
+func TestAlertmanagerNotify_Cycles(t *testing.T) {
+	posts := make(chan []AlertmanagerAlert, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alerts []AlertmanagerAlert
+		_ = json.NewDecoder(r.Body).Decode(&alerts)
+		posts <- alerts
+	}))
+	defer srv.Close()
+	ch := make(chan CheckResult)
+	an := newAlertmanagerNotify(&NotifyConfig{Type: "alertmanager", Config: map[string]any{"url": srv.URL}}, ch)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		an.Send(ctx, time.Hour)
+	}()
+	// firing为仍在告警的host/severity，其余的为已恢复
+	state := func(alerts []AlertmanagerAlert) (firing, resolved []string) {
+		for _, a := range alerts {
+			label := a.Labels["host"] + "/" + a.Labels["severity"]
+			if a.EndsAt.After(a.StartsAt) {
+				firing = append(firing, label)
+			} else {
+				resolved = append(resolved, label)
+			}
+		}
+		return firing, resolved
+	}
+	ch <- CheckResult{Host: "a.com", WarnMsg: "expires in 5 days", Severity: SeverityWarning}
+	ch <- CheckResult{Host: "b.com", WarnMsg: errExpired, Severity: SeverityCritical}
+	ch <- CycleEnd(false)
+	if firing, _ := state(<-posts); strings.Join(firing, ",") != "a.com/warning,b.com/critical" {
+		t.Errorf("unexpected firing alerts %v", firing)
+	}
+	// 被alertState抑制的告警仍然刷新endsAt，a.com变为critical时恢复warning，没有结果的b.com恢复
+	ch <- Repeated(CheckResult{Host: "a.com", WarnMsg: "expires in 40 hours", Severity: SeverityCritical})
+	ch <- CycleEnd(false)
+	firing, resolved := state(<-posts)
+	if strings.Join(firing, ",") != "a.com/critical" {
+		t.Errorf("unexpected firing alerts %v", firing)
+	}
+	// 只恢复上一轮告警中的Severity
+	slices.Sort(resolved)
+	if strings.Join(resolved, ",") != "a.com/warning,b.com/critical" {
+		t.Errorf("unexpected resolved alerts %v", resolved)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type WebhookNotify struct {
+	ch          <-chan CheckResult
+	url         string
+	method      string
+	contentType string
+	headers     map[string]string
+}
+
+func newWebhookNotify(config *NotifyConfig, in <-chan CheckResult) *WebhookNotify {
+	// 取值由Config.Validate检查
+	method := strings.ToUpper(config.GetDefault("method", http.MethodPost))
+	return &WebhookNotify{
+		ch:          in,
+		url:         config.Get("url"),
+		method:      method,
+		contentType: config.GetDefault("contentType", contentType),
+		headers:     config.GetMap("headers"),
+	}
+}
+
+// validateWebhookOptions method只能是POST或PUT
+func validateWebhookOptions(values map[string]any) []string {
+	if v, ok := optionalKey(values, "method"); ok {
+		if method := strings.ToUpper(v); method != http.MethodPost && method != http.MethodPut {
+			return []string{fmt.Sprintf("method %q must be POST or PUT", v)}
+		}
+	}
+	return nil
+}
+
+// WebhookPayload 不做任何格式化，原样输出结果，由接收方自行处理
+type WebhookPayload struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Results   []WebhookResult `json:"results"`
+}
+
+type WebhookResult struct {
+	Host              string   `json:"host"`
+	WarnMsg           string   `json:"warnMsg"`
+	DaysRemaining     int      `json:"daysRemaining"`
+	Issuer            string   `json:"issuer,omitempty"`
+	Subject           string   `json:"subject,omitempty"`
+	SerialNumber      string   `json:"serialNumber,omitempty"`
+	Severity          Severity `json:"severity"`
+	SHA256Fingerprint string   `json:"sha256Fingerprint,omitempty"`
+}
+
+func (wn *WebhookNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "webhook", wn.ch, waitTime, batchLimits{}, func(groups []resultGroup) error {
+		data, err := json.Marshal(webhookPayload(groups, time.Now()))
+		if err != nil {
+			return err
+		}
+		return wn.post(data)
+	})
+}
+
+func webhookPayload(groups []resultGroup, now time.Time) WebhookPayload {
+	payload := WebhookPayload{Timestamp: now.UTC(), Results: make([]WebhookResult, 0)}
+	for _, group := range groups {
+		for _, res := range group.Results {
+			payload.Results = append(payload.Results, WebhookResult{
+				Host:              res.Host,
+				WarnMsg:           res.WarnMsg,
+				DaysRemaining:     res.DaysRemaining,
+				Issuer:            res.Issuer,
+				Subject:           res.Subject,
+				SerialNumber:      res.SerialNumber,
+				Severity:          res.Severity,
+				SHA256Fingerprint: res.SHA256Fingerprint,
+			})
+		}
+	}
+	return payload
+}
+
+func (wn *WebhookNotify) post(data []byte) error {
+	req, err := http.NewRequest(wn.method, wn.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", wn.contentType)
+	for k, v := range wn.headers {
+		req.Header.Set(k, v)
+	}
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook responded %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/time/rate"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	wecomMaxBytes = 4096
+	// 企业微信群机器人每分钟最多发送20条消息
+	wecomRateBurst    = 20
+	wecomRateInterval = time.Minute / wecomRateBurst
+)
+
+type WeComNotify struct {
+	batchLimits
+	ch      <-chan CheckResult
+	url     string
+	limiter *rate.Limiter
+}
+
+func newWeComNotify(url string, in <-chan CheckResult) *WeComNotify {
+	return &WeComNotify{
+		ch:      in,
+		url:     url,
+		limiter: rate.NewLimiter(rate.Every(wecomRateInterval), wecomRateBurst),
+	}
+}
+
+type WeComMessage struct {
+	MsgType  string        `json:"msgtype"`
+	Markdown WeComMarkdown `json:"markdown"`
+}
+
+type WeComMarkdown struct {
+	Content string `json:"content"`
+}
+
+type weComResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (wn *WeComNotify) Send(ctx context.Context, waitTime time.Duration) {
+	collect(ctx, "wecom", wn.ch, waitTime, wn.batchLimits, func(groups []resultGroup) error {
+		for _, content := range chunkLines(wecomLines(groups), wecomMaxBytes) {
+			// ctx取消后仍需发送剩余消息，因此不使用ctx等待限流
+			if err := wn.limiter.Wait(context.Background()); err != nil {
+				return err
+			}
+			if err := wn.post(content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// post 企业微信出错时仍返回200，需要检查返回的errcode
+func (wn *WeComNotify) post(content string) error {
+	data, err := json.Marshal(WeComMessage{MsgType: "markdown", Markdown: WeComMarkdown{Content: content}})
+	if err != nil {
+		return err
+	}
+	httpClient := http.Client{Timeout: defaultTimeout}
+	resp, err := httpClient.Post(wn.url, contentType, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	wr := new(weComResponse)
+	if err = json.Unmarshal(body, wr); err != nil {
+		return fmt.Errorf("unexpected wecom response %s: %w", resp.Status, err)
+	}
+	if wr.ErrCode != 0 {
+		return fmt.Errorf("wecom error %d: %s", wr.ErrCode, wr.ErrMsg)
+	}
+	return nil
+}
+
+// wecomLines 每种告警一个加粗标题，下面列出对应的host
+func wecomLines(groups []resultGroup) []string {
+	lines := make([]string, 0)
+	for _, group := range groups {
+		lines = append(lines, fmt.Sprintf("**%s**", group.WarnMsg))
+		for _, host := range group.Hosts {
+			lines = append(lines, "> "+host)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	ocspCacheTTL = time.Minute * 10
+	// staplingWarnWindow 服务端应该在附带的OCSP响应过期前及时更新，剩余不足该时间时告警
+	staplingWarnWindow = time.Hour * 24
+)
+
+type ocspEntry struct {
+	status    int
+	expiresAt time.Time
+}
+
+// ocspCache 同一签发者下的证书（如泛域名证书的多个子域名）在有效期内只查询一次
+type ocspCache struct {
+	mu      sync.Mutex
+	entries map[string]ocspEntry
+}
+
+func newOCSPCache() *ocspCache {
+	return &ocspCache{entries: make(map[string]ocspEntry)}
+}
+
+// status 返回ocsp.Good、ocsp.Revoked或ocsp.Unknown
+func (oc *ocspCache) status(cert, issuer *x509.Certificate) (int, error) {
+	if len(cert.OCSPServer) == 0 {
+		return ocsp.Unknown, errors.New("certificate has no OCSP server")
+	}
+	key := string(issuer.SubjectKeyId) + cert.SerialNumber.String()
+	oc.mu.Lock()
+	entry, ok := oc.entries[key]
+	oc.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.status, nil
+	}
+	status, err := queryOCSP(cert, issuer)
+	if err != nil {
+		return ocsp.Unknown, err
+	}
+	oc.mu.Lock()
+	oc.entries[key] = ocspEntry{status: status, expiresAt: time.Now().Add(ocspCacheTTL)}
+	oc.mu.Unlock()
+	return status, nil
+}
+
+func queryOCSP(cert, issuer *x509.Certificate) (int, error) {
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return ocsp.Unknown, err
+	}
+	client := &http.Client{Timeout: defaultTimeout}
+	var lastErr error
+	for _, server := range cert.OCSPServer {
+		resp, err := client.Post(server, "application/ocsp-request", bytes.NewReader(req))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ocspResp, err := ocsp.ParseResponseForCert(body, cert, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return ocspResp.Status, nil
+	}
+	return ocsp.Unknown, lastErr
+}
+
+// checkStapledOCSP 检查握手时服务端附带的OCSP响应，没有附带或证书链中没有签发者时返回false
+func (sc *SimpleCheck) checkStapledOCSP(host string, state tls.ConnectionState) bool {
+	if len(state.OCSPResponse) == 0 || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) < 2 {
+		return false
+	}
+	cert, issuer := state.VerifiedChains[0][0], state.VerifiedChains[0][1]
+	now := time.Now()
+	resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, cert, issuer)
+	if err != nil {
+		sc.out <- newCertResult(host, fmt.Sprintf(errStapleInvalid, err), SeverityWarning, cert, now)
+		return true
+	}
+	switch {
+	case resp.Status == ocsp.Revoked:
+		sc.out <- newCertResult(host, errRevoked, SeverityCritical, cert, now)
+	case resp.NextUpdate.IsZero():
+		// 没有nextUpdate时表示随时可能有更新的信息，无法判断是否过期
+	case now.After(resp.NextUpdate):
+		sc.out <- newCertResult(host, errStapleExpired, SeverityWarning, cert, now)
+	case resp.NextUpdate.Sub(now) < staplingWarnWindow:
+		sc.out <- newCertResult(host, fmt.Sprintf(errStapleExpiring, int64(resp.NextUpdate.Sub(now).Hours())), SeverityWarning, cert, now)
+	default:
+		slog.Debug("stapled OCSP response is good", "host", host, "nextUpdate", resp.NextUpdate)
+	}
+	return true
+}
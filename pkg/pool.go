@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultProviderConcurrency 未配置providerConcurrency时所有provider同时进行的请求数
+const defaultProviderConcurrency = 20
+
+// WorkerPool 限制同时运行的任务数，任务在调用方的协程中运行，等待空闲的worker时可以被ctx取消
+type WorkerPool struct {
+	sem chan struct{}
+}
+
+// NewWorkerPool size不大于0时使用defaultProviderConcurrency
+func NewWorkerPool(size int) *WorkerPool {
+	if size <= 0 {
+		size = defaultProviderConcurrency
+	}
+	return &WorkerPool{sem: make(chan struct{}, size)}
+}
+
+// Do 等待空闲的worker后运行task并返回它的错误，ctx取消时不运行task，返回ctx.Err()。
+// task中不能再调用Do，否则worker用完时会互相等待
+func (wp *WorkerPool) Do(ctx context.Context, task func() error) error {
+	select {
+	case wp.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-wp.sem }()
+	return task()
+}
+
+var (
+	poolMu sync.RWMutex
+	// providerPool 所有provider的接口请求共用，由SetProviderConcurrency设置
+	providerPool = NewWorkerPool(defaultProviderConcurrency)
+)
+
+// SetProviderConcurrency 在启动和重新加载配置时调用，正在等待的请求仍使用旧的worker池
+func SetProviderConcurrency(size int) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	providerPool = NewWorkerPool(size)
+}
+
+// providerTask 在所有provider共用的worker池中完成一次接口请求，重试之间的等待不占用worker
+func providerTask(ctx context.Context, task func() error) error {
+	poolMu.RLock()
+	wp := providerPool
+	poolMu.RUnlock()
+	return wp.Do(ctx, task)
+}
+
+// doRequest 在worker池中发送req并读取完整的响应体，返回的resp.Body已经关闭。
+// 每次请求以debug级别记录状态码和耗时，只记录path，query中可能有密钥
+func doRequest(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	var resp *http.Response
+	var body []byte
+	err := providerTask(ctx, func() error {
+		start := time.Now()
+		var err error
+		if resp, err = client.Do(req); err != nil {
+			ctxLogger(ctx).Debug("provider request failed", "method", req.Method, "path", req.URL.Path, "elapsed", time.Since(start), "error", err)
+			return err
+		}
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		ctxLogger(ctx).Debug("provider request", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "bytes", len(body), "elapsed", time.Since(start))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_Do(t *testing.T) {
+	wp := NewWorkerPool(2)
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = wp.Do(context.Background(), func() error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 2 {
+		t.Errorf("expected at most 2 concurrent tasks, got %d", peak.Load())
+	}
+
+	// worker用完时ctx取消，task不会运行
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go wp.Do(context.Background(), func() error { <-release; return nil })
+	}
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ran := false
+	if err := wp.Do(ctx, func() error { ran = true; return nil }); err != context.DeadlineExceeded || ran {
+		t.Errorf("expected the task to be skipped, got %v, ran %v", err, ran)
+	}
+	close(release)
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,57 +9,435 @@ import (
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
 	util "github.com/alibabacloud-go/tea-utils/v2/service"
 	"github.com/alibabacloud-go/tea/tea"
-	"io"
-	"log"
+	credential "github.com/aliyun/credentials-go/credentials"
+	"golang.org/x/net/idna"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/time/rate"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	defaultSize = 100
-	maxRetry    = 3
-	aliyun      = "aliyun"
-	file        = "file"
-	west        = "west"
-	baseURL     = "https://api.west.cn/API/v2/domain/dns/"
-	queryAction = "dnsrec.list"
-	enable      = "ENABLE"
+	defaultSize  = 100
+	maxRetry     = 3
+	aliyun       = "aliyun"
+	file         = "file"
+	west         = "west"
+	route53      = "route53"
+	dnspod       = "dnspod"
+	httpList     = "http"
+	k8s          = "k8s"
+	rest         = "rest"
+	axfr         = "axfr"
+	certFile     = "certfile"
+	ns1          = "ns1"
+	digitalOcean = "digitalocean"
+	linode       = "linode"
+	huaweiDNS    = "huaweidns"
+	powerDNS     = "powerdns"
+	gandi        = "gandi"
+	vultr        = "vultr"
+	hetzner      = "hetzner"
+	jdcloud      = "jdcloud"
+	baseURL      = "https://api.west.cn/API/v2/domain/dns/"
+	queryAction  = "dnsrec.list"
+	enable       = "ENABLE"
+	// aliyunQPS 阿里云解析DescribeDomainRecords接口的默认调用频率，低于官方单用户限制
+	aliyunQPS = 10
+	// aliyunMaxPageSize DescribeDomainRecords接口PageSize的最大值
+	aliyunMaxPageSize = 500
+	// westQPS 西部数码接口的默认调用频率
+	westQPS = 5
+	// westConcurrency 西部数码同时查询的域名和记录类型数
+	westConcurrency = 4
+	// defaultRetryDelay 西部数码第一次重试前的等待时间
+	defaultRetryDelay = time.Second
+	// defaultMaxRetryDelay 西部数码重试等待时间的上限
+	defaultMaxRetryDelay = 30 * time.Second
 )
 
-var dnsTypes = [2]string{"A", "CNAME"}
+const errNoRecords = "provider returned no records, check its credentials and domains"
 
-func NewProvider(config *ProviderConfig) Provider {
+// defaultRecordTypes 未配置recordTypes时获取的记录类型
+var defaultRecordTypes = []string{"A", "CNAME"}
+
+// supportedRecordTypes recordTypes可以配置的记录类型
+var supportedRecordTypes = map[string]bool{"A": true, "AAAA": true, "CNAME": true}
+
+// parseRecordTypes 解析逗号分隔的记录类型，为空时返回nil
+func parseRecordTypes(value string) []string {
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.ToUpper(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// recordTypes provider配置的记录类型，未配置时使用defaultRecordTypes
+func recordTypes(config *ProviderConfig) []string {
+	if types := parseRecordTypes(config.GetDefault("recordTypes", "")); len(types) > 0 {
+		return types
+	}
+	return defaultRecordTypes
+}
+
+func NewProvider(config *ProviderConfig, defaults ProviderDefaults) Provider {
 	switch config.ProviderType {
 	case aliyun:
 		return newAliyunProvider(
-			config.Get("keyId"),
-			config.Get("keySecret"),
+			config.GetDefault("keyId", ""),
+			config.GetDefault("keySecret", ""),
+			config.GetDefault("securityToken", ""),
 			config.Get("region"),
-			strings.Split(config.Get("domains"), ","))
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			providerQPS(config, aliyunQPS),
+			defaults)
 	case file:
-		return newFileProvider(config.Get("filePath"))
+		// zone文件未配置recordTypes时获取全部支持的类型
+		return newFileProvider(config.Get("filePath"), parseRecordTypes(config.GetDefault("recordTypes", "")))
 	case west:
-		return &WestDigitalProvider{
-			apiKey:  config.Get("apiKey"),
-			domains: strings.Split(config.Get("domains"), ","),
-		}
+		return newWestDigitalProvider(
+			config.Get("apiKey"),
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			providerQPS(config, westQPS),
+			providerConcurrency(config, westConcurrency),
+			defaults,
+		)
+	case route53:
+		return newRoute53Provider(
+			config.Get("accessKeyId"),
+			config.Get("secretAccessKey"),
+			config.Get("region"),
+			strings.Split(config.Get("hostedZoneIds"), ","),
+			recordTypes(config),
+			defaults)
+	case dnspod:
+		return newDnspodProvider(
+			config.Get("secretId"),
+			config.Get("secretKey"),
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			defaults)
+	case httpList:
+		return newHTTPListProvider(config.Get("url"), config.GetDefault("authHeader", ""), defaults)
+	case rest:
+		return newRESTProvider(
+			config.Get("url"),
+			config.GetDefault("method", ""),
+			config.GetDefault("body", ""),
+			config.GetDefault("authHeader", ""),
+			config.GetMap("params"),
+			config.Get("recordsPath"),
+			config.GetDefault("hostField", ""),
+			config.GetDefault("typeField", ""),
+			recordTypes(config),
+			defaults)
+	case certFile:
+		return newCertFileProvider(config.Get("path"))
+	case digitalOcean:
+		return newDigitalOceanProvider(
+			config.Get("token"),
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			defaults)
+	case jdcloud:
+		return newJDCloudProvider(
+			config.Get("accessKey"),
+			config.Get("secretKey"),
+			config.GetDefault("region", "cn-north-1"),
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			providerQPS(config, jdcloudQPS),
+			defaults)
+	case huaweiDNS:
+		return newHuaweiDNSProvider(
+			config.Get("accessKey"),
+			config.Get("secretKey"),
+			config.Get("region"),
+			strings.Split(config.Get("zones"), ","),
+			recordTypes(config),
+			providerQPS(config, huaweiQPS),
+			defaults)
+	case powerDNS:
+		return newPowerDNSProvider(
+			config.Get("apiUrl"),
+			config.Get("apiKey"),
+			config.GetDefault("serverId", "localhost"),
+			strings.Split(config.Get("zones"), ","),
+			recordTypes(config),
+			defaults)
+	case gandi:
+		return newGandiProvider(
+			config.GetDefault("token", ""),
+			config.GetDefault("apiKey", ""),
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			defaults)
+	case vultr:
+		return newVultrProvider(
+			config.Get("apiKey"),
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			defaults)
+	case hetzner:
+		return newHetznerProvider(
+			config.Get("apiToken"),
+			strings.Split(config.Get("zones"), ","),
+			recordTypes(config),
+			defaults)
+	case linode:
+		return newLinodeProvider(
+			config.Get("token"),
+			strings.Split(config.Get("domains"), ","),
+			recordTypes(config),
+			defaults)
+	case ns1:
+		return newNS1Provider(
+			config.Get("apiKey"),
+			strings.Split(config.Get("zones"), ","),
+			recordTypes(config),
+			defaults)
+	case axfr:
+		return newAXFRProvider(
+			config.Get("server"),
+			strings.Split(config.Get("zones"), ","),
+			parseRecordTypes(config.GetDefault("recordTypes", "")),
+			config.GetDefault("tsigName", ""),
+			config.GetDefault("tsigSecret", ""),
+			config.GetDefault("tsigAlgo", "hmac-sha256"),
+			defaults)
+	case k8s:
+		return newK8sIngressProvider(
+			config.GetDefault("kubeconfig", ""),
+			config.GetDefault("context", ""),
+			strings.Split(config.GetDefault("namespaces", "*"), ","),
+			defaults)
 	default:
-		log.Fatalln("doesn't support provider", config.ProviderType)
+		fatal("doesn't support provider", "provider", config.Name, "type", config.ProviderType)
 	}
 	return nil
 }
 
+// Provider GetAllRecords返回即表示获取完成：实现内部启动的协程（例如分页请求）必须在返回前全部结束，
+// 调用方据此在所有provider返回后关闭ch
 type Provider interface {
-	GetAllRecords(ch chan<- string) // 结果写入ch，后续协程消费
+	GetAllRecords(ctx context.Context, ch chan<- string) // 结果写入ch，后续协程消费，全部写入后返回，ctx取消后停止获取
+}
+
+// GetHosts 获取provider的全部记录并附带告警天数写入out，全部写入后返回写入的host数量。
+// name为配置中provider的名字，和每次获取生成的enumeration一起出现在这次获取的所有日志中
+func GetHosts(ctx context.Context, name string, provider Provider, warnDays int, out chan<- Host) int {
+	ctx = withLogAttrs(ctx, "providerName", name, "enumeration", newEnumerationID())
+	start := time.Now()
+	count, hosts := 0, 0
+	defer func() {
+		ctxLogger(ctx).Debug("provider enumeration finished", "records", count, "hosts", hosts, "elapsed", time.Since(start))
+	}()
+	records := make(chan string)
+	go func() {
+		provider.GetAllRecords(ctx, records)
+		close(records)
+	}()
+	for record := range records {
+		count++
+		// ctx取消后继续读取并丢弃，避免provider阻塞在写入上
+		if ctx.Err() != nil {
+			continue
+		}
+		host := NewHost(record, warnDays)
+		if host.Host == "" {
+			continue
+		}
+		select {
+		case out <- host:
+			hosts++
+		case <-ctx.Done():
+		}
+	}
+	return hosts
+}
+
+// NoRecordsResult provider没有返回任何host时的结果，通常是凭证失效或域名配置错误，
+// 否则检查会在没有告警的情况下正常结束
+func NoRecordsResult(name string) CheckResult {
+	return CheckResult{Host: "provider:" + name, WarnMsg: errNoRecords, Severity: SeverityCritical}
+}
+
+// recordName 把DNS服务商返回的主机记录和域名拼接为完整域名，@或空为域名本身，*为泛域名。
+// 主机记录以.结尾或已经以域名结尾时视为完整域名，不再拼接
+func recordName(rr, domain string) string {
+	rr = strings.TrimSpace(rr)
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	lower, lowerDomain := strings.ToLower(rr), strings.ToLower(domain)
+	switch {
+	case rr == "" || rr == "@":
+		return domain
+	case strings.HasSuffix(rr, "."):
+		return strings.TrimSuffix(rr, ".")
+	case domain == "" || lower == lowerDomain || strings.HasSuffix(lower, "."+lowerDomain):
+		return rr
+	}
+	return rr + "." + domain
+}
+
+// throttledError 接口返回429，wait为服务端要求的等待时间，为0时按重试次数退避
+type throttledError struct {
+	wait time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return "rate limited"
+}
+
+// authError 接口返回401或403，凭据错误或没有权限时重试也不会成功
+type authError struct {
+	status string
+	body   []byte
+}
+
+func (e *authError) Error() string {
+	return fmt.Sprintf("authentication failed %s: %s", e.status, e.body)
+}
+
+// retryAfter 429响应中Retry-After的秒数，没有时为0，按重试次数退避
+func retryAfter(resp *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
 }
 
-func newAliyunProvider(keyId, keySecret, region string, domains []string) *AliyunProvider {
-	config := &openapi.Config{
-		AccessKeyId:     tea.String(keyId),
-		AccessKeySecret: tea.String(keySecret),
+// checkStatus 200以外的响应转为错误，429返回等待retryAfter的throttledError，401和403返回authError
+func checkStatus(resp *http.Response, body []byte) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusTooManyRequests:
+		return &throttledError{wait: retryAfter(resp)}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &authError{status: resp.Status, body: body}
+	}
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+}
+
+// withRetry 调用fn直到成功，最多defaults.retries()次，失败后按retryDelay等待再重试，最后一次失败后不再等待。
+// ctx取消或认证失败时立即返回
+func withRetry(ctx context.Context, defaults ProviderDefaults, fn func() error) error {
+	var err error
+	for retry := 0; retry < defaults.retries(); retry++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var ae *authError
+		if errors.As(err, &ae) || retry == defaults.retries()-1 {
+			return err
+		}
+		delay := retryDelay(defaults, err, retry)
+		ctxLogger(ctx).Warn("provider request failed, try again", "after", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// retryDelay 第retry次失败后的等待时间，被限流时优先使用服务端返回的时间，否则按defaults退避
+func retryDelay(defaults ProviderDefaults, err error, retry int) time.Duration {
+	var te *throttledError
+	if errors.As(err, &te) && te.wait > 0 {
+		return te.wait
+	}
+	return defaults.backoff(retry)
+}
+
+// backoff 第retry次失败后的等待时间，从retryDelay开始指数增长，不超过maxRetryDelay。
+// 实际等待时间在上限的一半到上限之间随机，避免大量域名同时失败后一起重试
+func (pd ProviderDefaults) backoff(retry int) time.Duration {
+	delay := pd.maxRetryDelay()
+	if base := pd.retryDelay(); retry < 32 && base<<retry > 0 && base<<retry < delay {
+		delay = base << retry
+	}
+	return delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1))
+}
+
+// rateLimitRetryAfter 响应头resetHeader为限额恢复时间的Unix时间戳，没有时使用Retry-After的秒数
+func rateLimitRetryAfter(header http.Header, resetHeader string, now time.Time) time.Duration {
+	if reset, err := strconv.ParseInt(header.Get(resetHeader), 10, 64); err == nil {
+		if wait := time.Unix(reset, 0).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// providerQPS 读取provider的qps配置，未配置时使用value，格式由Config.Validate检查
+func providerQPS(config *ProviderConfig, value float64) float64 {
+	if qps, err := parseQPS(config.GetDefault("qps", "")); err == nil {
+		return qps
+	}
+	return value
+}
+
+func parseQPS(v string) (float64, error) {
+	qps, err := strconv.ParseFloat(v, 64)
+	if err != nil || qps <= 0 {
+		return 0, fmt.Errorf("qps %q must be a positive number", v)
+	}
+	return qps, nil
+}
+
+// providerConcurrency 读取provider的concurrency配置，未配置时使用value，格式由Config.Validate检查
+func providerConcurrency(config *ProviderConfig, value int) int {
+	if n, err := parseConcurrency(config.GetDefault("concurrency", "")); err == nil {
+		return n
+	}
+	return value
+}
+
+func parseConcurrency(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("concurrency %q must be a positive integer", v)
+	}
+	return n, nil
+}
+
+// newAliyunProvider 配置了keyId和keySecret时使用静态密钥，同时配置securityToken时为STS临时凭证；
+// 都未配置时使用阿里云默认凭证链，依次读取环境变量、OIDC、配置文件和ECS实例RAM角色
+func newAliyunProvider(keyId, keySecret, securityToken, region string, domains, recordTypes []string, qps float64, defaults ProviderDefaults) *AliyunProvider {
+	config := &openapi.Config{}
+	if keyId != "" {
+		config.AccessKeyId = tea.String(keyId)
+		config.AccessKeySecret = tea.String(keySecret)
+		if securityToken != "" {
+			config.SecurityToken = tea.String(securityToken)
+		}
+	} else {
+		cred, err := credential.NewCredential(nil)
+		if err != nil {
+			fatal("create aliyun credential failed", "error", err)
+		}
+		config.Credential = cred
 	}
 	var endpoint string
 	if region == "cn-qingdao" || region == "cn-wulanchabu" {
@@ -67,25 +446,42 @@ func newAliyunProvider(keyId, keySecret, region string, domains []string) *Aliyu
 		endpoint = fmt.Sprintf("alidns.%s.aliyuncs.com", region)
 	}
 	config.Endpoint = tea.String(endpoint)
+	// SDK使用自己的HTTP客户端，未配置proxy时由SDK读取环境变量
+	if proxy, noProxy := proxyConfig(); proxy != "" {
+		config.HttpsProxy = tea.String(proxy)
+		config.NoProxy = tea.String(noProxy)
+	}
 	client, err := alidns20150109.NewClient(config)
 	if err != nil {
-		log.Fatalln(err)
+		fatal("create aliyun client failed", "error", err)
 	}
 	p := &AliyunProvider{
-		client:  client,
-		region:  region,
-		domains: domains,
+		client:      client,
+		region:      region,
+		domains:     domains,
+		recordTypes: recordTypes,
+		limiter:     rate.NewLimiter(rate.Limit(qps), 1),
+		defaults:    defaults,
 	}
 	return p
 }
 
+// aliyunClient AliyunProvider用到的alidns接口，便于测试时替换
+type aliyunClient interface {
+	DescribeDomainRecordsWithOptions(request *alidns20150109.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns20150109.DescribeDomainRecordsResponse, error)
+}
+
 type AliyunProvider struct {
-	client  *alidns20150109.Client
-	region  string
-	domains []string
+	client      aliyunClient
+	region      string
+	domains     []string
+	recordTypes []string
+	limiter     *rate.Limiter // 所有域名、类型和分页的请求共用，超过频率时等待而不是报错
+	defaults    ProviderDefaults
 }
 
-func (ap *AliyunProvider) fetchWithRetry(domain, dnsType string, page, pageSize int64, out chan<- string) (int64, error) {
+// fetchWithRetry 获取一页记录，返回该页的记录和接口返回的记录总数
+func (ap *AliyunProvider) fetchWithRetry(ctx context.Context, domain, dnsType string, page, pageSize int64) ([]string, int64, error) {
 	describeDomainRecordsRequest := &alidns20150109.DescribeDomainRecordsRequest{
 		Lang:       tea.String("en"),
 		PageSize:   tea.Int64(pageSize),
@@ -94,62 +490,157 @@ func (ap *AliyunProvider) fetchWithRetry(domain, dnsType string, page, pageSize
 		Type:       tea.String(dnsType),
 		Status:     tea.String(enable),
 	}
-	var lastErr error
-	for retry := 0; retry < maxRetry; retry++ {
-		runtime := &util.RuntimeOptions{}
-		resp, err := ap.client.DescribeDomainRecordsWithOptions(describeDomainRecordsRequest, runtime)
-		if err == nil && *resp.StatusCode == http.StatusOK {
-			for _, record := range resp.Body.DomainRecords.Record {
-				out <- fmt.Sprintf("%s.%s", *record.RR, domain)
-			}
-			cnt := *resp.Body.TotalCount / defaultSize
-			if *resp.Body.TotalCount%defaultSize != 0 {
-				cnt++
+	ctx = withLogAttrs(ctx, "page", page)
+	var records []string
+	var total int64
+	err := withRetry(ctx, ap.defaults, func() error {
+		if err := ap.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		var resp *alidns20150109.DescribeDomainRecordsResponse
+		err := providerTask(ctx, func() (err error) {
+			start := time.Now()
+			resp, err = ap.client.DescribeDomainRecordsWithOptions(describeDomainRecordsRequest, &util.RuntimeOptions{})
+			if err != nil {
+				ctxLogger(ctx).Debug("provider request failed", "action", "DescribeDomainRecords", "elapsed", time.Since(start), "error", err)
+			} else {
+				ctxLogger(ctx).Debug("provider request", "action", "DescribeDomainRecords", "status", tea.Int32Value(resp.StatusCode), "elapsed", time.Since(start))
 			}
-			return cnt, nil
-		} else {
-			lastErr = err
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if *resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code %d", *resp.StatusCode)
+		}
+		records = make([]string, 0, len(resp.Body.DomainRecords.Record))
+		for _, record := range resp.Body.DomainRecords.Record {
+			records = append(records, recordName(*record.RR, domain))
 		}
+		total = *resp.Body.TotalCount
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// pageCount 记录总数对应的页数
+func pageCount(total, pageSize int64) int64 {
+	cnt := total / pageSize
+	if total%pageSize != 0 {
+		cnt++
 	}
-	return -1, lastErr
+	return cnt
 }
 
-func (ap *AliyunProvider) getRecords(domain, dnsType string, out chan<- string) {
-	totalPage, err := ap.fetchWithRetry(domain, dnsType, 1, defaultSize, out)
-	if err != nil || totalPage < 0 {
-		log.Printf("Get domain %s total page failed %s", domain, err)
+// getRecords 先获取第1页得到总页数，再并发获取其余分页。失败的分页在本轮结束后重新获取一次，
+// 获取过程中记录总数增加时（例如期间新增了记录），继续获取新增的分页
+func (ap *AliyunProvider) getRecords(ctx context.Context, domain, dnsType string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", aliyun, "domain", domain, "type", dnsType)
+	pageSize := ap.defaults.pageSize()
+	records, total, err := ap.fetchWithRetry(ctx, domain, dnsType, 1, pageSize)
+	if err != nil {
+		ctxLogger(ctx).Warn("get domain total page failed", "error", err)
 		return
 	}
-	// 从第2页开始，因此减少1
-	for page := int64(2); page <= totalPage; page++ {
-		go ap.fetchWithRetry(domain, dnsType, page, defaultSize, out)
+	for _, record := range records {
+		out <- record
+	}
+	var mu sync.Mutex
+	failed := make(map[int64]error)
+	fetch := func(pages []int64) {
+		var wg sync.WaitGroup
+		for _, page := range pages {
+			wg.Add(1)
+			go func(page int64) {
+				defer wg.Done()
+				records, pageTotal, err := ap.fetchWithRetry(ctx, domain, dnsType, page, pageSize)
+				for _, record := range records {
+					out <- record
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed[page] = err
+					return
+				}
+				delete(failed, page)
+				if pageTotal > total {
+					total = pageTotal
+				}
+			}(page)
+		}
+		wg.Wait()
+	}
+	// 从第2页开始
+	fetched := int64(1)
+	for fetched < pageCount(total, pageSize) && ctx.Err() == nil {
+		last := pageCount(total, pageSize)
+		pages := make([]int64, 0, last-fetched)
+		for page := fetched + 1; page <= last; page++ {
+			pages = append(pages, page)
+		}
+		fetch(pages)
+		fetched = last
+	}
+	if len(failed) > 0 && ctx.Err() == nil {
+		pages := make([]int64, 0, len(failed))
+		for page := range failed {
+			pages = append(pages, page)
+		}
+		ctxLogger(ctx).Debug("retry failed pages", "pages", pages)
+		fetch(pages)
+	}
+	for page, err := range failed {
+		ctxLogger(ctx).Warn("get domain page failed", "page", page, "error", err)
 	}
 }
 
-func (ap *AliyunProvider) GetAllRecords(out chan<- string) {
+func (ap *AliyunProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
 	for _, domain := range ap.domains {
-		for _, dnsType := range dnsTypes {
-			go ap.getRecords(strings.TrimSpace(domain), dnsType, out)
+		for _, dnsType := range ap.recordTypes {
+			wg.Add(1)
+			go func(domain, dnsType string) {
+				defer wg.Done()
+				ap.getRecords(ctx, domain, dnsType, out)
+			}(strings.TrimSpace(domain), dnsType)
 		}
 	}
+	wg.Wait()
 }
 
 // file
-func newFileProvider(path string) *FileProvider {
-	return &FileProvider{file: path}
+func newFileProvider(path string, recordTypes []string) *FileProvider {
+	return &FileProvider{file: path, recordTypes: recordTypes}
 }
 
 type FileProvider struct {
-	file string
+	file        string
+	recordTypes []string // 只用于zone文件，为nil时获取全部支持的类型
 }
 
-func (fp *FileProvider) GetAllRecords(out chan<- string) {
+func (fp *FileProvider) GetAllRecords(_ context.Context, out chan<- string) {
 	contents, err := os.ReadFile(fp.file)
 	if err != nil {
-		log.Println("WARN read file error", err)
+		slog.Warn("read file error", "provider", file, "path", fp.file, "error", err)
 		return
 	}
-	lines := strings.Split(string(contents), "\n")
+	if isZoneFile(string(contents)) {
+		for _, host := range parseZoneFile(string(contents), fp.recordTypes) {
+			out <- host
+		}
+		return
+	}
+	writeHostLines(string(contents), out)
+}
+
+// writeHostLines 每行一个host，忽略空行和#开头的注释
+func writeHostLines(contents string, out chan<- string) {
+	lines := strings.Split(contents, "\n")
 	for _, line := range lines {
 		host := strings.TrimSpace(line)
 		if len(host) == 0 || host[0] == '#' {
@@ -162,9 +653,9 @@ func (fp *FileProvider) GetAllRecords(out chan<- string) {
 // WestDigital
 
 type WBody struct {
-	Pageno  int
+	Pageno    int
 	Pagecount int
-	Items []map[string]any `json:"items"`
+	Items     []map[string]any `json:"items"`
 }
 
 type WestResponse struct {
@@ -173,65 +664,122 @@ type WestResponse struct {
 }
 
 type WestDigitalProvider struct {
-	apiKey  string
-	domains []string
+	url         string
+	apiKey      string
+	domains     []string
+	recordTypes []string
+	limiter     *rate.Limiter // 所有域名、类型和分页的请求共用
+	concurrency int
+	defaults    ProviderDefaults
 }
 
-func (wd *WestDigitalProvider) GetAllRecords(ch chan<- string) {
+func newWestDigitalProvider(apiKey string, domains, recordTypes []string, qps float64, concurrency int, defaults ProviderDefaults) *WestDigitalProvider {
+	return &WestDigitalProvider{
+		url:         baseURL,
+		apiKey:      apiKey,
+		domains:     domains,
+		recordTypes: recordTypes,
+		limiter:     rate.NewLimiter(rate.Limit(qps), 1),
+		concurrency: concurrency,
+		defaults:    defaults,
+	}
+}
+
+// GetAllRecords 每个域名和记录类型一个查询，最多同时进行concurrency个
+func (wd *WestDigitalProvider) GetAllRecords(ctx context.Context, ch chan<- string) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, wd.concurrency)
 	for _, domain := range wd.domains {
-		for _, recordType := range dnsTypes {
+		for _, recordType := range wd.recordTypes {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			}
+			wg.Add(1)
 			go func(domain, recordType string) {
-				for i := 0; i < maxRetry; i++ {
-					if err := wd.queryDomainRecord(domain, recordType, ch); err != nil {
-						log.Println("WARN provider west digital get record failed, try again in 1 seconds")
-						time.Sleep(time.Second)
-						continue
-					}
-					return
+				defer wg.Done()
+				defer func() { <-sem }()
+				ctx := withLogAttrs(ctx, "provider", west, "domain", domain, "type", recordType)
+				err := withRetry(ctx, wd.defaults, func() error {
+					return wd.queryDomainRecord(ctx, domain, recordType, ch)
+				})
+				if err != nil && ctx.Err() == nil {
+					ctxLogger(ctx).Error("get record failed exceed max retry", "retry", wd.defaults.retries(), "error", err)
 				}
-				log.Println("ERROR provider west digital failed exceed ", maxRetry)
 			}(domain, recordType)
 		}
 	}
+	wg.Wait()
 }
 
-func (wd *WestDigitalProvider) doAction(path string, param map[string]string, isGet bool) ([]byte, error) {
-	var apiPath string
-	if path != "" {
-		apiPath = fmt.Sprintf("%s%s", baseURL, path)
-	} else {
-		apiPath = baseURL
+// encodeGBK 西部数码接口按GBK解析参数，中文域名需要先转为GBK再做URL编码
+func encodeGBK(values url.Values) (string, error) {
+	encoded := url.Values{}
+	encoder := simplifiedchinese.GBK.NewEncoder()
+	for k, vs := range values {
+		for _, v := range vs {
+			gbk, err := encoder.String(v)
+			if err != nil {
+				return "", fmt.Errorf("encode %s as GBK: %w", k, err)
+			}
+			encoded.Add(k, gbk)
+		}
 	}
-	var req *http.Request
-	var err error
+	return encoded.Encode(), nil
+}
+
+// decodeResponse 响应的Content-Type声明为GBK时转为UTF-8
+func decodeResponse(contentType string, body []byte) ([]byte, error) {
+	_, params, _ := mime.ParseMediaType(contentType)
+	switch strings.ToLower(params["charset"]) {
+	case "gbk", "gb2312":
+		return simplifiedchinese.GBK.NewDecoder().Bytes(body)
+	case "gb18030":
+		return simplifiedchinese.GB18030.NewDecoder().Bytes(body)
+	}
+	return body, nil
+}
+
+func (wd *WestDigitalProvider) doAction(ctx context.Context, path string, param map[string]string, isGet bool) ([]byte, error) {
+	apiPath := wd.url + path
 	query := url.Values{}
 	query.Add("apidomainkey", wd.apiKey)
 	for k, v := range param {
 		query.Add(k, v)
 	}
+	body, err := encodeGBK(query)
+	if err != nil {
+		return nil, err
+	}
+	if err = wd.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	var req *http.Request
 	client := &http.Client{Timeout: defaultTimeout}
 	if isGet {
-		req, err = http.NewRequest(http.MethodGet, apiPath, nil)
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, apiPath+"?"+body, nil)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		req, err = http.NewRequest(http.MethodPost, apiPath, strings.NewReader(query.Encode()))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, apiPath, strings.NewReader(body))
 		if err != nil {
 			return nil, err
 		}
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=GBK")
 	}
-	resp, err := client.Do(req)
+	resp, data, err := doRequest(ctx, client, req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
+	return decodeResponse(resp.Header.Get("Content-Type"), data)
 }
 
-func (wd *WestDigitalProvider) fetch(param map[string]string) (error, *WestResponse) {
-	resp, err := wd.doAction("", param, false)
+func (wd *WestDigitalProvider) fetch(ctx context.Context, param map[string]string) (error, *WestResponse) {
+	ctx = withLogAttrs(ctx, "page", param["pageno"])
+	resp, err := wd.doAction(ctx, "", param, false)
 	if err != nil {
 		return err, nil
 	}
@@ -245,34 +793,65 @@ func (wd *WestDigitalProvider) fetch(param map[string]string) (error, *WestRespo
 	return nil, wp
 }
 
-func (wd *WestDigitalProvider) queryDomainRecord(domain, recordType string, out chan<- string) error {
+func (wd *WestDigitalProvider) queryDomainRecord(ctx context.Context, domain, recordType string, out chan<- string) error {
 	// 不带参数时，默认为第一页
 	param := map[string]string{
 		"act":         queryAction,
 		"domain":      domain,
 		"record_type": recordType,
 		"pageno":      "1",
+		"limit":       strconv.FormatInt(wd.defaults.pageSize(), 10),
 	}
-	err, wp := wd.fetch(param)
+	err, wp := wd.fetch(ctx, param)
 	if err != nil {
 		return err
 	}
-	for _, record := range wp.Body.Items {
-		if record["pause"].(float64) == 0 {
-			out <- fmt.Sprintf("%s.%s", record["hostname"], domain)
-		}
-	}
+	writeWestRecords(wp.Body.Items, domain, out)
 	for i := 2; i <= wp.Body.Pagecount; i++ {
 		param["pageno"] = fmt.Sprintf("%d", i)
-		err, wp = wd.fetch(param)
+		err, wp = wd.fetch(ctx, param)
 		if err != nil {
 			return err
 		}
-		for _, record := range wp.Body.Items {
-			if record["pause"].(float64) == 0 {
-				out <- fmt.Sprintf("%s.%s", record["hostname"], domain)
-			}
-		}
+		writeWestRecords(wp.Body.Items, domain, out)
 	}
 	return nil
 }
+
+// westRecordName 启用的记录返回完整的host，pause为1的记录已暂停。
+// pause或hostname缺失、类型不对时记录日志并跳过，接口格式变化不会导致panic
+func westRecordName(record map[string]any, domain string) (string, bool) {
+	var active bool
+	switch pause := record["pause"].(type) {
+	case float64:
+		active = pause == 0
+	case string:
+		active = pause == "0"
+	default:
+		slog.Warn("skip record with invalid pause", "provider", west, "domain", domain, "record", record)
+		return "", false
+	}
+	rr, ok := record["hostname"].(string)
+	if !ok {
+		slog.Warn("skip record with invalid hostname", "provider", west, "domain", domain, "record", record)
+		return "", false
+	}
+	if !active {
+		return "", false
+	}
+	// 中文域名转为punycode，连接时才能解析
+	host := recordName(rr, domain)
+	if ascii, err := idna.ToASCII(host); err == nil {
+		return ascii, true
+	}
+	return host, true
+}
+
+// writeWestRecords 把一页中启用的记录写入out
+func writeWestRecords(items []map[string]any, domain string, out chan<- string) {
+	for _, record := range items {
+		if host, ok := westRecordName(record, domain); ok {
+			out <- host
+		}
+	}
+}
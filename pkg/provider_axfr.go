@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"github.com/miekg/dns"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tsigAlgorithms tsigAlgo可以配置的算法
+var tsigAlgorithms = map[string]string{
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// newAXFRProvider server未指定端口时使用53，recordTypes为空时获取A、AAAA和CNAME
+func newAXFRProvider(server string, zones, recordTypes []string, tsigName, tsigSecret, tsigAlgo string, defaults ProviderDefaults) *AXFRProvider {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	if len(recordTypes) == 0 {
+		recordTypes = []string{"A", "AAAA", "CNAME"}
+	}
+	algo, ok := tsigAlgorithms[strings.ToLower(tsigAlgo)]
+	if !ok {
+		fatal("unsupported tsig algorithm", "provider", axfr, "tsigAlgo", tsigAlgo)
+	}
+	return &AXFRProvider{
+		server:      server,
+		zones:       zones,
+		recordTypes: recordTypes,
+		tsigName:    tsigName,
+		tsigSecret:  tsigSecret,
+		tsigAlgo:    algo,
+		defaults:    defaults,
+	}
+}
+
+// AXFRProvider 通过区域传送获取zone中的全部记录，配置了tsigName时使用TSIG签名请求
+type AXFRProvider struct {
+	server      string
+	zones       []string
+	recordTypes []string
+	tsigName    string
+	tsigSecret  string
+	tsigAlgo    string
+	defaults    ProviderDefaults
+}
+
+// transfer 获取一个zone中指定类型记录的名称，AXFR响应可能分为多个消息，全部读取后才返回
+func (xp *AXFRProvider) transfer(ctx context.Context, zone string) ([]string, error) {
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(zone))
+	t := &dns.Transfer{DialTimeout: defaultTimeout, ReadTimeout: defaultTimeout}
+	if xp.tsigName != "" {
+		name := dns.Fqdn(xp.tsigName)
+		m.SetTsig(name, xp.tsigAlgo, 300, time.Now().Unix())
+		t.TsigSecret = map[string]string{name: xp.tsigSecret}
+	}
+	envelopes, err := t.In(m, xp.server)
+	if err != nil {
+		return nil, err
+	}
+	// ctx取消时关闭连接，让t.In结束并关闭envelopes
+	stop := context.AfterFunc(ctx, func() { t.Close() })
+	defer stop()
+	names := make([]string, 0)
+	var transferErr error
+	for env := range envelopes {
+		if env.Error != nil {
+			transferErr = env.Error
+			continue
+		}
+		for _, rr := range env.RR {
+			if !slices.Contains(xp.recordTypes, dns.TypeToString[rr.Header().Rrtype]) {
+				continue
+			}
+			names = append(names, strings.TrimSuffix(rr.Header().Name, "."))
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return names, transferErr
+}
+
+func (xp *AXFRProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	for _, zone := range xp.zones {
+		zone = strings.TrimSpace(zone)
+		var names []string
+		err := withRetry(ctx, xp.defaults, func() error {
+			return providerTask(ctx, func() (err error) {
+				start := time.Now()
+				names, err = xp.transfer(ctx, zone)
+				ctxLogger(ctx).Debug("provider request", "action", "AXFR", "zone", zone, "records", len(names), "elapsed", time.Since(start), "error", err)
+				return err
+			})
+		})
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				ctxLogger(ctx).Warn("zone transfer failed", "provider", axfr, "server", xp.server, "zone", zone, "error", err)
+			}
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				out <- name
+			}
+		}
+		ctxLogger(ctx).Debug("zone transferred", "provider", axfr, "zone", zone, "hosts", len(seen))
+	}
+}
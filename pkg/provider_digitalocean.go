@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	digitalOceanBaseURL = "https://api.digitalocean.com/v2"
+	// digitalOceanPageSize per_page的最大值
+	digitalOceanPageSize = 200
+)
+
+// linkNextPattern 匹配Link响应头中rel="next"的地址
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+type DigitalOceanRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type DigitalOceanResponse struct {
+	DomainRecords []DigitalOceanRecord `json:"domain_records"`
+	Links         struct {
+		Pages struct {
+			Next string `json:"next"`
+		} `json:"pages"`
+	} `json:"links"`
+}
+
+func newDigitalOceanProvider(token string, domains, recordTypes []string, defaults ProviderDefaults) *DigitalOceanProvider {
+	return &DigitalOceanProvider{
+		url:         digitalOceanBaseURL,
+		token:       token,
+		domains:     domains,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// DigitalOceanProvider 按记录类型分别查询每个域名的记录，沿着下一页的链接读取全部分页
+type DigitalOceanProvider struct {
+	url         string
+	token       string
+	domains     []string
+	recordTypes []string
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// nextPage 下一页的地址，优先使用Link响应头，其次是响应中的links.pages.next，没有下一页时为空
+func nextPage(header http.Header, dr *DigitalOceanResponse) string {
+	if m := linkNextPattern.FindStringSubmatch(header.Get("Link")); m != nil {
+		return m[1]
+	}
+	return dr.Links.Pages.Next
+}
+
+// digitalOceanRetryAfter RateLimit-Reset为限额恢复时间的Unix时间戳
+func digitalOceanRetryAfter(header http.Header, now time.Time) time.Duration {
+	return rateLimitRetryAfter(header, "RateLimit-Reset", now)
+}
+
+// fetchPage 读取一页记录，返回记录和下一页的地址
+func (dp *DigitalOceanProvider) fetchPage(ctx context.Context, pageURL string) ([]DigitalOceanRecord, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+dp.token)
+	resp, body, err := doRequest(ctx, dp.client, req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, "", &throttledError{wait: digitalOceanRetryAfter(resp.Header, time.Now())}
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return nil, "", err
+	}
+	dr := new(DigitalOceanResponse)
+	if err = json.Unmarshal(body, dr); err != nil {
+		return nil, "", err
+	}
+	return dr.DomainRecords, nextPage(resp.Header, dr), nil
+}
+
+func (dp *DigitalOceanProvider) fetchPageWithRetry(ctx context.Context, pageURL string) ([]DigitalOceanRecord, string, error) {
+	var records []DigitalOceanRecord
+	var next string
+	err := withRetry(ctx, dp.defaults, func() (err error) {
+		records, next, err = dp.fetchPage(ctx, pageURL)
+		return err
+	})
+	return records, next, err
+}
+
+func (dp *DigitalOceanProvider) getRecords(ctx context.Context, domain, recordType string, out chan<- string) {
+	query := url.Values{"type": {recordType}, "per_page": {strconv.Itoa(digitalOceanPageSize)}}
+	pageURL := fmt.Sprintf("%s/domains/%s/records?%s", dp.url, url.PathEscape(domain), query.Encode())
+	ctx = withLogAttrs(ctx, "provider", digitalOcean, "domain", domain, "type", recordType)
+	for page := 1; pageURL != ""; page++ {
+		records, next, err := dp.fetchPageWithRetry(withLogAttrs(ctx, "page", page), pageURL)
+		if err != nil {
+			ctxLogger(ctx).Error("get record failed exceed max retry", "retry", dp.defaults.retries(), "error", err)
+			return
+		}
+		for _, record := range records {
+			if record.Type == recordType {
+				out <- recordName(record.Name, domain)
+			}
+		}
+		pageURL = next
+	}
+}
+
+func (dp *DigitalOceanProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, domain := range dp.domains {
+		for _, recordType := range dp.recordTypes {
+			wg.Add(1)
+			go func(domain, recordType string) {
+				defer wg.Done()
+				dp.getRecords(ctx, domain, recordType, out)
+			}(strings.TrimSpace(domain), recordType)
+		}
+	}
+	wg.Wait()
+}
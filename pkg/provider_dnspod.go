@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dnspodBaseURL    = "https://dnspod.tencentcloudapi.com"
+	dnspodService    = "dnspod"
+	dnspodVersion    = "2021-03-23"
+	dnspodAction     = "DescribeRecordList"
+	dnspodNoRecord   = "ResourceNotFound.NoDataOfRecord"
+	tc3Algorithm     = "TC3-HMAC-SHA256"
+	tc3ContentType   = "application/json; charset=utf-8"
+	tc3SignedHeaders = "content-type;host"
+)
+
+type DnspodRecord struct {
+	Name   string `json:"Name"`
+	Type   string `json:"Type"`
+	Status string `json:"Status"`
+}
+
+type DnspodResponse struct {
+	Response struct {
+		RecordCountInfo struct {
+			TotalCount int64 `json:"TotalCount"`
+		} `json:"RecordCountInfo"`
+		RecordList []DnspodRecord `json:"RecordList"`
+		Error      *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+	} `json:"Response"`
+}
+
+func newDnspodProvider(secretId, secretKey string, domains, recordTypes []string, defaults ProviderDefaults) *DnspodProvider {
+	return &DnspodProvider{
+		secretId:    secretId,
+		secretKey:   secretKey,
+		domains:     domains,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+		url:         dnspodBaseURL,
+	}
+}
+
+type DnspodProvider struct {
+	secretId    string
+	secretKey   string
+	domains     []string
+	recordTypes []string
+	defaults    ProviderDefaults
+	client      *http.Client
+	url         string
+}
+
+// sign 按腾讯云API 3.0的TC3-HMAC-SHA256规则生成Authorization头
+func (dp *DnspodProvider) sign(host string, payload []byte, timestamp int64) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		fmt.Sprintf("content-type:%s\nhost:%s\n", tc3ContentType, host),
+		tc3SignedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/tc3_request", date, dnspodService)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		tc3Algorithm,
+		strconv.FormatInt(timestamp, 10),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+	secretDate := hmacSHA256([]byte("TC3"+dp.secretKey), date)
+	secretService := hmacSHA256(secretDate, dnspodService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		tc3Algorithm, dp.secretId, scope, tc3SignedHeaders, signature)
+}
+
+func (dp *DnspodProvider) describeRecordList(ctx context.Context, domain, dnsType string, offset, limit int64) (*DnspodResponse, error) {
+	payload, err := json.Marshal(map[string]any{
+		"Domain":     domain,
+		"RecordType": dnsType,
+		"Offset":     offset,
+		"Limit":      limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dp.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", tc3ContentType)
+	req.Header.Set("X-TC-Action", dnspodAction)
+	req.Header.Set("X-TC-Version", dnspodVersion)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Authorization", dp.sign(req.URL.Host, payload, timestamp))
+	_, body, err := doRequest(ctx, dp.client, req)
+	if err != nil {
+		return nil, err
+	}
+	dr := new(DnspodResponse)
+	if err = json.Unmarshal(body, dr); err != nil {
+		return nil, err
+	}
+	return dr, nil
+}
+
+func (dp *DnspodProvider) fetchWithRetry(ctx context.Context, domain, dnsType string, page, pageSize int64, out chan<- string) (int64, error) {
+	ctx = withLogAttrs(ctx, "provider", dnspod, "domain", domain, "type", dnsType, "page", page)
+	var resp *DnspodResponse
+	err := withRetry(ctx, dp.defaults, func() (err error) {
+		resp, err = dp.describeRecordList(ctx, domain, dnsType, (page-1)*pageSize, pageSize)
+		if err != nil {
+			return err
+		}
+		// 域名下没有该类型的记录时，接口以错误的形式返回
+		if e := resp.Response.Error; e != nil && e.Code != dnspodNoRecord {
+			return errors.New(e.Code + ": " + e.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	if resp.Response.Error != nil {
+		return 0, nil
+	}
+	for _, record := range resp.Response.RecordList {
+		if record.Status != enable {
+			continue
+		}
+		out <- recordName(record.Name, domain)
+	}
+	return pageCount(resp.Response.RecordCountInfo.TotalCount, pageSize), nil
+}
+
+func (dp *DnspodProvider) getRecords(ctx context.Context, domain, dnsType string, out chan<- string) {
+	pageSize := dp.defaults.pageSize()
+	totalPage, err := dp.fetchWithRetry(ctx, domain, dnsType, 1, pageSize, out)
+	if err != nil || totalPage < 0 {
+		ctxLogger(ctx).Warn("get domain total page failed", "provider", dnspod, "domain", domain, "type", dnsType, "error", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for page := int64(2); page <= totalPage; page++ {
+		wg.Add(1)
+		go func(page int64) {
+			defer wg.Done()
+			if _, err := dp.fetchWithRetry(ctx, domain, dnsType, page, pageSize, out); err != nil {
+				ctxLogger(ctx).Warn("get domain page failed", "provider", dnspod, "domain", domain, "type", dnsType, "page", page, "error", err)
+			}
+		}(page)
+	}
+	wg.Wait()
+}
+
+func (dp *DnspodProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, domain := range dp.domains {
+		for _, dnsType := range dp.recordTypes {
+			wg.Add(1)
+			go func(domain, dnsType string) {
+				defer wg.Done()
+				dp.getRecords(ctx, domain, dnsType, out)
+			}(strings.TrimSpace(domain), dnsType)
+		}
+	}
+	wg.Wait()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
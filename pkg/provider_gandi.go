@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const gandiBaseURL = "https://api.gandi.net/v5/livedns"
+
+// GandiRecord LiveDNS的记录集，rrset_name为相对域名的名称，@为域名本身
+type GandiRecord struct {
+	Name string `json:"rrset_name"`
+	Type string `json:"rrset_type"`
+}
+
+// newGandiProvider token为个人访问令牌，apiKey为旧版API key，只使用其中一个
+func newGandiProvider(token, apiKey string, domains, recordTypes []string, defaults ProviderDefaults) *GandiProvider {
+	authorization := "Bearer " + token
+	if token == "" {
+		authorization = "Apikey " + apiKey
+	}
+	return &GandiProvider{
+		url:           gandiBaseURL,
+		authorization: authorization,
+		domains:       domains,
+		recordTypes:   recordTypes,
+		defaults:      defaults,
+		client:        &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// GandiProvider 通过LiveDNS API一次读取每个域名的全部记录集，不需要分页
+type GandiProvider struct {
+	url           string
+	authorization string
+	domains       []string
+	recordTypes   []string
+	defaults      ProviderDefaults
+	client        *http.Client
+}
+
+func (gp *GandiProvider) fetchRecords(ctx context.Context, domain string) ([]GandiRecord, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gp.url+"/domains/"+url.PathEscape(domain)+"/records", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", gp.authorization)
+	resp, body, err := doRequest(ctx, gp.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return nil, err
+	}
+	records := make([]GandiRecord, 0)
+	if err = json.Unmarshal(body, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (gp *GandiProvider) getRecords(ctx context.Context, domain string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", gandi, "domain", domain)
+	var records []GandiRecord
+	err := withRetry(ctx, gp.defaults, func() (err error) {
+		records, err = gp.fetchRecords(ctx, domain)
+		return err
+	})
+	if err != nil {
+		ctxLogger(ctx).Error("get record failed exceed max retry", "retry", gp.defaults.retries(), "error", err)
+		return
+	}
+	for _, record := range records {
+		if slices.Contains(gp.recordTypes, record.Type) {
+			out <- recordName(record.Name, domain)
+		}
+	}
+}
+
+func (gp *GandiProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, domain := range gp.domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			gp.getRecords(ctx, domain, out)
+		}(strings.TrimSpace(domain))
+	}
+	wg.Wait()
+}
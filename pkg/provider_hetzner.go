@@ -0,0 +1,149 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	hetznerBaseURL = "https://dns.hetzner.com/api/v1"
+	// hetznerPageSize per_page的最大值
+	hetznerPageSize = 100
+)
+
+type HetznerZone struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type HetznerZoneList struct {
+	Zones []HetznerZone `json:"zones"`
+}
+
+type HetznerRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// HetznerRecordList 分页响应，page从1开始，last_page为最后一页
+type HetznerRecordList struct {
+	Records []HetznerRecord `json:"records"`
+	Meta    struct {
+		Pagination struct {
+			Page     int `json:"page"`
+			LastPage int `json:"last_page"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+func newHetznerProvider(apiToken string, zones, recordTypes []string, defaults ProviderDefaults) *HetznerProvider {
+	return &HetznerProvider{
+		url:         hetznerBaseURL,
+		apiToken:    apiToken,
+		zones:       zones,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// HetznerProvider zones可以是zone名称或ID，名称先按name查询得到ID，再分页读取zone的全部记录
+type HetznerProvider struct {
+	url         string
+	apiToken    string
+	zones       []string
+	recordTypes []string
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// get 请求path并把响应解析到v
+func (hp *HetznerProvider) get(ctx context.Context, path string, query url.Values, v any) error {
+	reqURL := hp.url + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Auth-API-Token", hp.apiToken)
+	resp, body, err := doRequest(ctx, hp.client, req)
+	if err != nil {
+		return err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (hp *HetznerProvider) getWithRetry(ctx context.Context, path string, query url.Values, v any) error {
+	return withRetry(ctx, hp.defaults, func() error {
+		return hp.get(ctx, path, query, v)
+	})
+}
+
+// lookupZone zone名称包含.，否则作为ID直接读取
+func (hp *HetznerProvider) lookupZone(ctx context.Context, zone string) (HetznerZone, error) {
+	if !strings.Contains(zone, ".") {
+		var resp struct {
+			Zone HetznerZone `json:"zone"`
+		}
+		err := hp.getWithRetry(ctx, "/zones/"+url.PathEscape(zone), nil, &resp)
+		return resp.Zone, err
+	}
+	var list HetznerZoneList
+	if err := hp.getWithRetry(ctx, "/zones", url.Values{"name": {zone}}, &list); err != nil {
+		return HetznerZone{}, err
+	}
+	for _, hz := range list.Zones {
+		if strings.EqualFold(hz.Name, zone) {
+			return hz, nil
+		}
+	}
+	return HetznerZone{}, fmt.Errorf("zone %s not found", zone)
+}
+
+func (hp *HetznerProvider) getRecords(ctx context.Context, zone string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", hetzner, "zone", zone)
+	hz, err := hp.lookupZone(ctx, zone)
+	if err != nil {
+		ctxLogger(ctx).Error("get zone failed", "error", err)
+		return
+	}
+	pageSize := min(hp.defaults.pageSize(), hetznerPageSize)
+	for page, lastPage := 1, 1; page <= lastPage; page++ {
+		query := url.Values{"zone_id": {hz.Id}, "page": {strconv.Itoa(page)}, "per_page": {strconv.FormatInt(pageSize, 10)}}
+		var records HetznerRecordList
+		if err = hp.getWithRetry(withLogAttrs(ctx, "page", page), "/records", query, &records); err != nil {
+			ctxLogger(ctx).Error("get record failed exceed max retry", "page", page, "retry", hp.defaults.retries(), "error", err)
+			return
+		}
+		for _, record := range records.Records {
+			if slices.Contains(hp.recordTypes, record.Type) {
+				out <- recordName(record.Name, hz.Name)
+			}
+		}
+		lastPage = records.Meta.Pagination.LastPage
+	}
+}
+
+func (hp *HetznerProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, zone := range hp.zones {
+		wg.Add(1)
+		go func(zone string) {
+			defer wg.Done()
+			hp.getRecords(ctx, zone, out)
+		}(strings.TrimSpace(zone))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func newHTTPListProvider(url, authHeader string, defaults ProviderDefaults) *HTTPListProvider {
+	return &HTTPListProvider{
+		url:        url,
+		authHeader: authHeader,
+		defaults:   defaults,
+		client:     &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// HTTPListProvider 从HTTP接口获取host列表，支持按行分隔的文本或JSON字符串数组
+type HTTPListProvider struct {
+	url        string
+	authHeader string
+	defaults   ProviderDefaults
+	client     *http.Client
+}
+
+func (hp *HTTPListProvider) fetch(ctx context.Context) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hp.url, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if hp.authHeader != "" {
+		req.Header.Set("Authorization", hp.authHeader)
+	}
+	resp, body, err := doRequest(ctx, hp.client, req)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return "", nil, err
+	}
+	return resp.Header.Get("Content-Type"), body, nil
+}
+
+func (hp *HTTPListProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", httpList, "url", hp.url)
+	var contentType string
+	var body []byte
+	err := withRetry(ctx, hp.defaults, func() (err error) {
+		contentType, body, err = hp.fetch(ctx)
+		return err
+	})
+	if err != nil {
+		ctxLogger(ctx).Warn("fetch failed exceed max retry", "retry", hp.defaults.retries(), "error", err)
+		return
+	}
+	if err = writeHTTPListBody(contentType, body, out); err != nil {
+		ctxLogger(ctx).Warn("parse body failed", "error", err)
+	}
+}
+
+func writeHTTPListBody(contentType string, body []byte, out chan<- string) error {
+	if !strings.Contains(contentType, "json") {
+		writeHostLines(string(body), out)
+		return nil
+	}
+	var hosts []string
+	if err := json.Unmarshal(body, &hosts); err != nil {
+		return err
+	}
+	writeHostLines(strings.Join(hosts, "\n"), out)
+	return nil
+}
@@ -0,0 +1,193 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	huaweiAlgorithm     = "SDK-HMAC-SHA256"
+	huaweiDateFormat    = "20060102T150405Z"
+	huaweiSignedHeaders = "host;x-sdk-date"
+	huaweiActive        = "ACTIVE"
+	// huaweiPageSize ListRecordSets接口limit的最大值
+	huaweiPageSize = 500
+	// huaweiQPS 华为云解析接口的默认调用频率
+	huaweiQPS = 10
+)
+
+type HuaweiRecordSet struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	ZoneName string `json:"zone_name"`
+}
+
+type HuaweiRecordSetList struct {
+	RecordSets []HuaweiRecordSet `json:"recordsets"`
+	Links      struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+func newHuaweiDNSProvider(accessKey, secretKey, region string, zones, recordTypes []string, qps float64, defaults ProviderDefaults) *HuaweiDNSProvider {
+	return &HuaweiDNSProvider{
+		url:         fmt.Sprintf("https://dns.%s.myhuaweicloud.com", region),
+		accessKey:   accessKey,
+		secretKey:   secretKey,
+		zones:       zones,
+		recordTypes: recordTypes,
+		limiter:     rate.NewLimiter(rate.Limit(qps), 1),
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// HuaweiDNSProvider 通过ListRecordSets按记录类型查询公网域名的记录集，按marker分页，
+// 所有域名共用limiter限制请求频率
+type HuaweiDNSProvider struct {
+	url         string
+	accessKey   string
+	secretKey   string
+	zones       []string
+	recordTypes []string
+	limiter     *rate.Limiter
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// huaweiEscape 按RFC 3986编码，空格编码为%20而不是+
+func huaweiEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// huaweiQuery 按参数名排序并编码，同时用于请求地址和签名
+func huaweiQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, huaweiEscape(key)+"="+huaweiEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sign 按华为云API网关的SDK-HMAC-SHA256规则生成Authorization头，GET请求的body为空
+func (hp *HuaweiDNSProvider) sign(host, path, query, date string) string {
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	payloadHash := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		path,
+		query,
+		fmt.Sprintf("host:%s\nx-sdk-date:%s\n", host, date),
+		huaweiSignedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{huaweiAlgorithm, date, hex.EncodeToString(requestHash[:])}, "\n")
+	signature := hex.EncodeToString(hmacSHA256([]byte(hp.secretKey), stringToSign))
+	return fmt.Sprintf("%s Access=%s, SignedHeaders=%s, Signature=%s", huaweiAlgorithm, hp.accessKey, huaweiSignedHeaders, signature)
+}
+
+func (hp *HuaweiDNSProvider) listRecordSets(ctx context.Context, query url.Values) (*HuaweiRecordSetList, error) {
+	u, err := url.Parse(hp.url + "/v2/recordsets")
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = huaweiQuery(query)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	date := time.Now().UTC().Format(huaweiDateFormat)
+	req.Header.Set("X-Sdk-Date", date)
+	req.Header.Set("Authorization", hp.sign(u.Host, u.EscapedPath(), u.RawQuery, date))
+	resp, body, err := doRequest(ctx, hp.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return nil, err
+	}
+	list := new(HuaweiRecordSetList)
+	if err = json.Unmarshal(body, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// fetchWithRetry 与阿里云一样每次请求前先等待limiter
+func (hp *HuaweiDNSProvider) fetchWithRetry(ctx context.Context, query url.Values) (*HuaweiRecordSetList, error) {
+	var list *HuaweiRecordSetList
+	err := withRetry(ctx, hp.defaults, func() (err error) {
+		if err = hp.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		list, err = hp.listRecordSets(ctx, query)
+		return err
+	})
+	return list, err
+}
+
+// getRecords name按包含匹配，只保留zone_name与zone相同的记录集
+func (hp *HuaweiDNSProvider) getRecords(ctx context.Context, zone, recordType string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", huaweiDNS, "zone", zone, "type", recordType)
+	zoneName := strings.TrimSuffix(zone, ".") + "."
+	pageSize := min(hp.defaults.pageSize(), huaweiPageSize)
+	query := url.Values{
+		"zone_type": {"public"},
+		"type":      {recordType},
+		"name":      {zoneName},
+		"limit":     {strconv.FormatInt(pageSize, 10)},
+	}
+	for page := 1; ; page++ {
+		list, err := hp.fetchWithRetry(withLogAttrs(ctx, "page", page), query)
+		if err != nil {
+			ctxLogger(ctx).Error("get record failed exceed max retry", "page", page, "retry", hp.defaults.retries(), "error", err)
+			return
+		}
+		for _, rs := range list.RecordSets {
+			if rs.Type == recordType && rs.Status == huaweiActive && strings.EqualFold(rs.ZoneName, zoneName) {
+				out <- strings.TrimSuffix(rs.Name, ".")
+			}
+		}
+		if int64(len(list.RecordSets)) < pageSize || list.Links.Next == "" {
+			return
+		}
+		query.Set("marker", list.RecordSets[len(list.RecordSets)-1].Id)
+	}
+}
+
+func (hp *HuaweiDNSProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, zone := range hp.zones {
+		for _, recordType := range hp.recordTypes {
+			wg.Add(1)
+			go func(zone, recordType string) {
+				defer wg.Done()
+				hp.getRecords(ctx, zone, recordType, out)
+			}(strings.TrimSpace(zone), recordType)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,199 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jdcloudBaseURL       = "https://clouddns.jdcloud-api.com"
+	jdcloudService       = "clouddns"
+	jdcloudAlgorithm     = "JDCLOUD2-HMAC-SHA256"
+	jdcloudDateFormat    = "20060102T150405Z"
+	jdcloudSignedHeaders = "host;x-jdcloud-date;x-jdcloud-nonce"
+	// jdcloudPageSize pageSize的最大值
+	jdcloudPageSize = 100
+	// jdcloudQPS 京东云解析接口的默认调用频率
+	jdcloudQPS = 10
+)
+
+type JDCloudDomain struct {
+	Id         int    `json:"id"`
+	DomainName string `json:"domainName"`
+}
+
+// JDCloudRecord hostRecord为相对域名的主机记录，@为域名本身
+type JDCloudRecord struct {
+	HostRecord string `json:"hostRecord"`
+	Type       string `json:"type"`
+}
+
+type JDCloudDomainList struct {
+	Result struct {
+		DataList []JDCloudDomain `json:"dataList"`
+	} `json:"result"`
+}
+
+// JDCloudRecordList 分页响应，pageNumber从1开始，totalPage为总页数
+type JDCloudRecordList struct {
+	Result struct {
+		DataList  []JDCloudRecord `json:"dataList"`
+		TotalPage int             `json:"totalPage"`
+	} `json:"result"`
+}
+
+func newJDCloudProvider(accessKey, secretKey, region string, domains, recordTypes []string, qps float64, defaults ProviderDefaults) *JDCloudProvider {
+	return &JDCloudProvider{
+		url:         jdcloudBaseURL,
+		accessKey:   accessKey,
+		secretKey:   secretKey,
+		region:      region,
+		domains:     domains,
+		recordTypes: recordTypes,
+		limiter:     rate.NewLimiter(rate.Limit(qps), 1),
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// JDCloudProvider domains可以是域名或数字ID，域名先查询得到ID，再分页读取全部解析记录，
+// 所有域名共用limiter限制请求频率
+type JDCloudProvider struct {
+	url         string
+	accessKey   string
+	secretKey   string
+	region      string
+	domains     []string
+	recordTypes []string
+	limiter     *rate.Limiter
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// sign 按京东云JDCLOUD2-HMAC-SHA256规则生成Authorization头，与AWS Signature V4相同，GET请求的body为空
+func (jp *JDCloudProvider) sign(host, path, query, date, nonce string) string {
+	payloadHash := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		path,
+		query,
+		fmt.Sprintf("host:%s\nx-jdcloud-date:%s\nx-jdcloud-nonce:%s\n", host, date, nonce),
+		jdcloudSignedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	day := date[:8]
+	scope := strings.Join([]string{day, jp.region, jdcloudService, "jdcloud2_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{jdcloudAlgorithm, date, scope, hex.EncodeToString(requestHash[:])}, "\n")
+	key := hmacSHA256([]byte("JDCLOUD2"+jp.secretKey), day)
+	for _, part := range []string{jp.region, jdcloudService, "jdcloud2_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", jdcloudAlgorithm, jp.accessKey, scope, jdcloudSignedHeaders, signature)
+}
+
+// get 请求regions/{region}下的path并把响应解析到v，查询参数按huaweiQuery排序编码
+func (jp *JDCloudProvider) get(ctx context.Context, path string, query url.Values, v any) error {
+	u, err := url.Parse(fmt.Sprintf("%s/v1/regions/%s%s", jp.url, url.PathEscape(jp.region), path))
+	if err != nil {
+		return err
+	}
+	u.RawQuery = huaweiQuery(query)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	date := time.Now().UTC().Format(jdcloudDateFormat)
+	req.Header.Set("X-Jdcloud-Date", date)
+	req.Header.Set("X-Jdcloud-Nonce", hex.EncodeToString(nonce))
+	req.Header.Set("Authorization", jp.sign(u.Host, u.EscapedPath(), u.RawQuery, date, hex.EncodeToString(nonce)))
+	resp, body, err := doRequest(ctx, jp.client, req)
+	if err != nil {
+		return err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// fetchWithRetry 与阿里云一样每次请求前先等待limiter
+func (jp *JDCloudProvider) fetchWithRetry(ctx context.Context, path string, query url.Values, v any) error {
+	return withRetry(ctx, jp.defaults, func() error {
+		if err := jp.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		return jp.get(ctx, path, query, v)
+	})
+}
+
+// lookupDomain 按数字ID或域名查询，得到ID和域名
+func (jp *JDCloudProvider) lookupDomain(ctx context.Context, domain string) (JDCloudDomain, error) {
+	query := url.Values{"pageNumber": {"1"}, "pageSize": {strconv.Itoa(jdcloudPageSize)}, "domainName": {domain}}
+	id, err := strconv.Atoi(domain)
+	if err == nil {
+		query = url.Values{"pageNumber": {"1"}, "pageSize": {"1"}, "domainId": {domain}}
+	}
+	var list JDCloudDomainList
+	if err = jp.fetchWithRetry(ctx, "/domain", query, &list); err != nil {
+		return JDCloudDomain{}, err
+	}
+	for _, jd := range list.Result.DataList {
+		if jd.Id == id || strings.EqualFold(jd.DomainName, domain) {
+			return jd, nil
+		}
+	}
+	return JDCloudDomain{}, fmt.Errorf("domain %s not found", domain)
+}
+
+func (jp *JDCloudProvider) getRecords(ctx context.Context, domain string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", jdcloud, "domain", domain)
+	jd, err := jp.lookupDomain(ctx, domain)
+	if err != nil {
+		ctxLogger(ctx).Error("get domain failed", "error", err)
+		return
+	}
+	path := fmt.Sprintf("/domain/%d/ResourceRecord", jd.Id)
+	pageSize := min(jp.defaults.pageSize(), jdcloudPageSize)
+	for page, pages := 1, 1; page <= pages; page++ {
+		query := url.Values{"pageNumber": {strconv.Itoa(page)}, "pageSize": {strconv.FormatInt(pageSize, 10)}}
+		var records JDCloudRecordList
+		if err = jp.fetchWithRetry(withLogAttrs(ctx, "page", page), path, query, &records); err != nil {
+			ctxLogger(ctx).Error("get record failed exceed max retry", "page", page, "retry", jp.defaults.retries(), "error", err)
+			return
+		}
+		for _, record := range records.Result.DataList {
+			if slices.Contains(jp.recordTypes, record.Type) {
+				out <- recordName(record.HostRecord, jd.DomainName)
+			}
+		}
+		pages = records.Result.TotalPage
+	}
+}
+
+func (jp *JDCloudProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, domain := range jp.domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			jp.getRecords(ctx, domain, out)
+		}(strings.TrimSpace(domain))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,330 @@
+package pkg
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	k8sIngressPath       = "/apis/networking.k8s.io/v1"
+	k8sPageSize          = 500
+)
+
+type K8sIngress struct {
+	Spec struct {
+		Rules []struct {
+			Host string `json:"host"`
+		} `json:"rules"`
+		TLS []struct {
+			Hosts []string `json:"hosts"`
+		} `json:"tls"`
+	} `json:"spec"`
+}
+
+type K8sIngressList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []K8sIngress `json:"items"`
+}
+
+// kubeconfig 只解析访问API server需要的字段
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Exec                  any    `yaml:"exec"`
+			AuthProvider          any    `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// k8sClient 访问API server的地址、认证和HTTP客户端
+type k8sClient struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+func newK8sIngressProvider(kubeconfigPath, contextName string, namespaces []string, defaults ProviderDefaults) *K8sIngressProvider {
+	return &K8sIngressProvider{
+		kubeconfig:  kubeconfigPath,
+		contextName: contextName,
+		namespaces:  namespaces,
+		defaults:    defaults,
+	}
+}
+
+// K8sIngressProvider 获取Kubernetes Ingress的spec.rules[].host和spec.tls[].hosts[]。
+// 未配置kubeconfig时在集群内使用ServiceAccount，否则使用$KUBECONFIG或~/.kube/config
+type K8sIngressProvider struct {
+	kubeconfig  string
+	contextName string   // 为空时使用kubeconfig的current-context
+	namespaces  []string // 包含*时获取全部namespace
+	defaults    ProviderDefaults
+}
+
+// connect 每轮检查重新读取凭证，ServiceAccount的token会定期轮换
+func (kp *K8sIngressProvider) connect() (*k8sClient, error) {
+	path := kp.kubeconfig
+	if path == "" {
+		if host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"); host != "" {
+			return inClusterClient(net.JoinHostPort(host, port))
+		}
+		path = os.Getenv("KUBECONFIG")
+		if path == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(home, ".kube", "config")
+		}
+		// KUBECONFIG可以是多个文件，只使用第一个
+		path = filepath.SplitList(path)[0]
+	}
+	return kubeconfigClient(path, kp.contextName)
+}
+
+func inClusterClient(addr string) (*k8sClient, error) {
+	token, err := os.ReadFile(filepath.Join(k8sServiceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	pool, err := LoadCertPool(filepath.Join(k8sServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	return &k8sClient{
+		server: "https://" + addr,
+		token:  strings.TrimSpace(string(token)),
+		client: &http.Client{Timeout: defaultTimeout, Transport: &http.Transport{Proxy: httpProxy, TLSClientConfig: &tls.Config{RootCAs: pool}}},
+	}, nil
+}
+
+// readKubeconfigData 优先使用内联的base64数据，否则读取文件，相对路径相对于kubeconfig所在目录
+func readKubeconfigData(dir, data, path string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return os.ReadFile(path)
+}
+
+func kubeconfigClient(path, contextName string) (*k8sClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kc kubeconfig
+	if err = yaml.Unmarshal(data, &kc); err != nil {
+		return nil, err
+	}
+	if contextName == "" {
+		contextName = kc.CurrentContext
+	}
+	var clusterName, userName string
+	for _, c := range kc.Contexts {
+		if c.Name == contextName {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("context %q not found in %s", contextName, path)
+	}
+	dir := filepath.Dir(path)
+	kcl := &k8sClient{}
+	tlsConfig := &tls.Config{}
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		kcl.server = strings.TrimRight(c.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		ca, err := readKubeconfigData(dir, c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority)
+		if err != nil {
+			return nil, err
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificates found in certificate authority of cluster %s", clusterName)
+			}
+		}
+	}
+	if kcl.server == "" {
+		return nil, fmt.Errorf("cluster %q not found in %s", clusterName, path)
+	}
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		// 不支持exec和auth-provider插件，静默忽略会以匿名用户访问并得到401/403
+		if u.User.Token == "" && u.User.TokenFile == "" && u.User.ClientCertificate == "" && u.User.ClientCertificateData == "" {
+			if u.User.Exec != nil {
+				return nil, fmt.Errorf("unsupported kubeconfig auth exec for user %s, use token, tokenFile or client certificate", userName)
+			}
+			if u.User.AuthProvider != nil {
+				return nil, fmt.Errorf("unsupported kubeconfig auth auth-provider for user %s, use token, tokenFile or client certificate", userName)
+			}
+		}
+		kcl.token = u.User.Token
+		if u.User.TokenFile != "" {
+			token, err := readKubeconfigData(dir, "", u.User.TokenFile)
+			if err != nil {
+				return nil, err
+			}
+			kcl.token = strings.TrimSpace(string(token))
+		}
+		certPEM, err := readKubeconfigData(dir, u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := readKubeconfigData(dir, u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		if certPEM != nil {
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+	kcl.client = &http.Client{Timeout: defaultTimeout, Transport: &http.Transport{Proxy: httpProxy, TLSClientConfig: tlsConfig}}
+	return kcl, nil
+}
+
+func (kc *k8sClient) get(ctx context.Context, path string, query url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, kc.server+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if kc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+kc.token)
+	}
+	resp, body, err := doRequest(ctx, kc.client, req)
+	if err != nil {
+		return err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// listIngresses 按页获取namespace下的全部Ingress，namespace为空时获取全部namespace
+func (kc *k8sClient) listIngresses(ctx context.Context, namespace string, defaults ProviderDefaults) ([]K8sIngress, error) {
+	path := k8sIngressPath + "/ingresses"
+	if namespace != "" {
+		path = k8sIngressPath + "/namespaces/" + url.PathEscape(namespace) + "/ingresses"
+	}
+	ingresses := make([]K8sIngress, 0)
+	query := url.Values{"limit": {fmt.Sprint(k8sPageSize)}}
+	ctx = withLogAttrs(ctx, "provider", k8s, "namespace", namespace)
+	for page := 1; ; page++ {
+		ctx := withLogAttrs(ctx, "page", page)
+		var list K8sIngressList
+		err := withRetry(ctx, defaults, func() error {
+			list = K8sIngressList{}
+			return kc.get(ctx, path, query, &list)
+		})
+		if err != nil {
+			return nil, err
+		}
+		ingresses = append(ingresses, list.Items...)
+		if list.Metadata.Continue == "" {
+			return ingresses, nil
+		}
+		query.Set("continue", list.Metadata.Continue)
+	}
+}
+
+// scopes 配置中的namespace，包含*时只需要一次全部namespace的请求
+func (kp *K8sIngressProvider) scopes() []string {
+	scopes := make([]string, 0, len(kp.namespaces))
+	for _, ns := range kp.namespaces {
+		if ns = strings.TrimSpace(ns); ns == "*" || ns == "" {
+			return []string{""}
+		}
+		scopes = append(scopes, strings.TrimSpace(ns))
+	}
+	return scopes
+}
+
+func (kp *K8sIngressProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	kc, err := kp.connect()
+	if err != nil {
+		ctxLogger(ctx).Warn("connect kubernetes failed", "provider", k8s, "error", err)
+		return
+	}
+	seen := make(map[string]bool)
+	emit := func(host string) {
+		if host == "" || seen[host] {
+			return
+		}
+		seen[host] = true
+		out <- host
+	}
+	for _, ns := range kp.scopes() {
+		ingresses, err := kc.listIngresses(ctx, ns, kp.defaults)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				ctxLogger(ctx).Warn("list ingresses failed", "provider", k8s, "namespace", ns, "error", err)
+			}
+			continue
+		}
+		for _, ing := range ingresses {
+			for _, rule := range ing.Spec.Rules {
+				emit(rule.Host)
+			}
+			for _, t := range ing.Spec.TLS {
+				for _, host := range t.Hosts {
+					emit(host)
+				}
+			}
+		}
+	}
+}
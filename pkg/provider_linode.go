@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	linodeBaseURL = "https://api.linode.com/v4"
+	// linodePageSize page_size的最大值
+	linodePageSize = 500
+)
+
+type LinodeDomain struct {
+	Id     int    `json:"id"`
+	Domain string `json:"domain"`
+}
+
+type LinodeRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// LinodeDomainList 列表接口的分页响应，page从1开始，pages为总页数
+type LinodeDomainList struct {
+	Data  []LinodeDomain `json:"data"`
+	Page  int            `json:"page"`
+	Pages int            `json:"pages"`
+}
+
+type LinodeRecordList struct {
+	Data  []LinodeRecord `json:"data"`
+	Page  int            `json:"page"`
+	Pages int            `json:"pages"`
+}
+
+func newLinodeProvider(token string, domains, recordTypes []string, defaults ProviderDefaults) *LinodeProvider {
+	return &LinodeProvider{
+		url:         linodeBaseURL,
+		token:       token,
+		domains:     domains,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// LinodeProvider domains可以是域名或数字ID，域名先通过过滤查询得到ID，再分页读取每个域名的全部记录
+type LinodeProvider struct {
+	url         string
+	token       string
+	domains     []string
+	recordTypes []string
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// get 请求path并把响应解析到v，filter不为空时作为X-Filter请求头
+func (lp *LinodeProvider) get(ctx context.Context, path string, query url.Values, filter string, v any) error {
+	reqURL := lp.url + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+lp.token)
+	if filter != "" {
+		req.Header.Set("X-Filter", filter)
+	}
+	resp, body, err := doRequest(ctx, lp.client, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &throttledError{wait: rateLimitRetryAfter(resp.Header, "X-RateLimit-Reset", time.Now())}
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (lp *LinodeProvider) getWithRetry(ctx context.Context, path string, query url.Values, filter string, v any) error {
+	return withRetry(ctx, lp.defaults, func() error {
+		return lp.get(ctx, path, query, filter, v)
+	})
+}
+
+// lookupDomain 数字ID直接读取域名，否则按域名过滤查询
+func (lp *LinodeProvider) lookupDomain(ctx context.Context, domain string) (LinodeDomain, error) {
+	if _, err := strconv.Atoi(domain); err == nil {
+		var ld LinodeDomain
+		err = lp.getWithRetry(ctx, "/domains/"+domain, nil, "", &ld)
+		return ld, err
+	}
+	filter, err := json.Marshal(map[string]string{"domain": domain})
+	if err != nil {
+		return LinodeDomain{}, err
+	}
+	var page LinodeDomainList
+	if err = lp.getWithRetry(ctx, "/domains", nil, string(filter), &page); err != nil {
+		return LinodeDomain{}, err
+	}
+	for _, ld := range page.Data {
+		if strings.EqualFold(ld.Domain, domain) {
+			return ld, nil
+		}
+	}
+	return LinodeDomain{}, fmt.Errorf("domain %s not found", domain)
+}
+
+func (lp *LinodeProvider) getRecords(ctx context.Context, domain string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", linode, "domain", domain)
+	ld, err := lp.lookupDomain(ctx, domain)
+	if err != nil {
+		ctxLogger(ctx).Error("get domain failed", "error", err)
+		return
+	}
+	path := fmt.Sprintf("/domains/%d/records", ld.Id)
+	for page, pages := 1, 1; page <= pages; page++ {
+		query := url.Values{"page": {strconv.Itoa(page)}, "page_size": {strconv.Itoa(linodePageSize)}}
+		var records LinodeRecordList
+		if err = lp.getWithRetry(withLogAttrs(ctx, "page", page), path, query, "", &records); err != nil {
+			ctxLogger(ctx).Error("get record failed exceed max retry", "page", page, "retry", lp.defaults.retries(), "error", err)
+			return
+		}
+		for _, record := range records.Data {
+			if slices.Contains(lp.recordTypes, record.Type) {
+				out <- recordName(record.Name, ld.Domain)
+			}
+		}
+		pages = records.Pages
+	}
+}
+
+func (lp *LinodeProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, domain := range lp.domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			lp.getRecords(ctx, domain, out)
+		}(strings.TrimSpace(domain))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ns1BaseURL = "https://api.nsone.net/v1"
+
+// NS1Record /v1/zones/{zone}返回的记录摘要，domain为完整的域名
+type NS1Record struct {
+	Domain string `json:"domain"`
+	Type   string `json:"type"`
+}
+
+type NS1Zone struct {
+	Zone    string      `json:"zone"`
+	Records []NS1Record `json:"records"`
+}
+
+func newNS1Provider(apiKey string, zones, recordTypes []string, defaults ProviderDefaults) *NS1Provider {
+	return &NS1Provider{
+		url:         ns1BaseURL,
+		apiKey:      apiKey,
+		zones:       zones,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// NS1Provider 读取NS1 zone中的记录，zone对象包含全部记录，不需要分页
+type NS1Provider struct {
+	url         string
+	apiKey      string
+	zones       []string
+	recordTypes []string
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// ns1RetryAfter NS1在X-RateLimit-Period秒内限制请求数，没有返回Retry-After
+func ns1RetryAfter(header http.Header) time.Duration {
+	for _, key := range []string{"Retry-After", "X-RateLimit-Period"} {
+		if seconds, err := strconv.Atoi(header.Get(key)); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+func (np *NS1Provider) fetchZone(ctx context.Context, zone string) (*NS1Zone, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, np.url+"/zones/"+url.PathEscape(zone), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-NSONE-Key", np.apiKey)
+	resp, body, err := doRequest(ctx, np.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &throttledError{wait: ns1RetryAfter(resp.Header)}
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return nil, err
+	}
+	nz := new(NS1Zone)
+	if err = json.Unmarshal(body, nz); err != nil {
+		return nil, err
+	}
+	return nz, nil
+}
+
+func (np *NS1Provider) getRecords(ctx context.Context, zone string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", ns1, "zone", zone)
+	var nz *NS1Zone
+	err := withRetry(ctx, np.defaults, func() (err error) {
+		nz, err = np.fetchZone(ctx, zone)
+		return err
+	})
+	if err != nil {
+		ctxLogger(ctx).Error("get zone failed exceed max retry", "retry", np.defaults.retries(), "error", err)
+		return
+	}
+	for _, record := range nz.Records {
+		if slices.Contains(np.recordTypes, record.Type) {
+			out <- record.Domain
+		}
+	}
+}
+
+func (np *NS1Provider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, zone := range np.zones {
+		wg.Add(1)
+		go func(zone string) {
+			defer wg.Done()
+			np.getRecords(ctx, zone, out)
+		}(strings.TrimSpace(zone))
+	}
+	wg.Wait()
+}
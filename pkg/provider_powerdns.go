@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type PowerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+// PowerDNSRRSet name为以.结尾的完整域名
+type PowerDNSRRSet struct {
+	Name    string           `json:"name"`
+	Type    string           `json:"type"`
+	Records []PowerDNSRecord `json:"records"`
+}
+
+type PowerDNSZone struct {
+	Name   string          `json:"name"`
+	RRSets []PowerDNSRRSet `json:"rrsets"`
+}
+
+func newPowerDNSProvider(apiURL, apiKey, serverId string, zones, recordTypes []string, defaults ProviderDefaults) *PowerDNSProvider {
+	return &PowerDNSProvider{
+		url:         strings.TrimSuffix(strings.TrimRight(apiURL, "/"), "/api/v1") + "/api/v1/servers/" + url.PathEscape(serverId),
+		apiKey:      apiKey,
+		zones:       zones,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// PowerDNSProvider 通过PowerDNS权威服务器的HTTP API读取zone，zone对象包含全部rrsets，不需要分页
+type PowerDNSProvider struct {
+	url         string
+	apiKey      string
+	zones       []string
+	recordTypes []string
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// powerDNSZoneId zone的id为以.结尾的规范名称
+func powerDNSZoneId(zone string) string {
+	return strings.TrimSuffix(zone, ".") + "."
+}
+
+func (pp *PowerDNSProvider) fetchZone(ctx context.Context, zone string) (*PowerDNSZone, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pp.url+"/zones/"+url.PathEscape(powerDNSZoneId(zone)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", pp.apiKey)
+	resp, body, err := doRequest(ctx, pp.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return nil, err
+	}
+	pz := new(PowerDNSZone)
+	if err = json.Unmarshal(body, pz); err != nil {
+		return nil, err
+	}
+	return pz, nil
+}
+
+// enabled rrset中至少有一条未停用的记录
+func (rs PowerDNSRRSet) enabled() bool {
+	return slices.ContainsFunc(rs.Records, func(r PowerDNSRecord) bool { return !r.Disabled })
+}
+
+func (pp *PowerDNSProvider) getRecords(ctx context.Context, zone string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", powerDNS, "zone", zone)
+	var pz *PowerDNSZone
+	err := withRetry(ctx, pp.defaults, func() (err error) {
+		pz, err = pp.fetchZone(ctx, zone)
+		return err
+	})
+	if err != nil {
+		ctxLogger(ctx).Error("get zone failed exceed max retry", "retry", pp.defaults.retries(), "error", err)
+		return
+	}
+	for _, rs := range pz.RRSets {
+		if slices.Contains(pp.recordTypes, rs.Type) && rs.enabled() {
+			out <- strings.TrimSuffix(rs.Name, ".")
+		}
+	}
+}
+
+func (pp *PowerDNSProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, zone := range pp.zones {
+		wg.Add(1)
+		go func(zone string) {
+			defer wg.Done()
+			pp.getRecords(ctx, zone, out)
+		}(strings.TrimSpace(zone))
+	}
+	wg.Wait()
+}
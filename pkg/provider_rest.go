@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// newRESTProvider method为空时使用GET，typeField为空时不按记录类型过滤
+func newRESTProvider(rawURL, method, body, authHeader string, params map[string]string, recordsPath, hostField, typeField string, recordTypes []string, defaults ProviderDefaults) *RESTProvider {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return &RESTProvider{
+		url:         rawURL,
+		method:      strings.ToUpper(method),
+		body:        body,
+		authHeader:  authHeader,
+		params:      params,
+		recordsPath: recordsPath,
+		hostField:   hostField,
+		typeField:   typeField,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// RESTProvider 通用的JSON接口，recordsPath指向响应中的记录数组，hostField为记录中host所在的字段，
+// 路径用.分隔，数字表示数组下标，例如data.records或items.0.name。hostField为空时数组元素本身就是host
+type RESTProvider struct {
+	url         string
+	method      string
+	body        string // 请求体，例如把凭证放在JSON中的POST接口
+	authHeader  string
+	params      map[string]string // 追加到url的查询参数
+	recordsPath string
+	hostField   string
+	typeField   string   // 记录中类型所在的字段
+	recordTypes []string // 配置了typeField时只保留这些类型的记录
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+// lookupPath 按.分隔的路径在JSON解析结果中取值，路径为空时返回v本身
+func lookupPath(v any, path string) (any, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return nil, fmt.Errorf("key %q not found", key)
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("invalid index %q", key)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("cannot look up %q in %T", key, v)
+		}
+	}
+	return v, nil
+}
+
+func (rp *RESTProvider) fetch(ctx context.Context) ([]byte, error) {
+	u, err := url.Parse(rp.url)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	for k, v := range rp.params {
+		query.Set(k, v)
+	}
+	u.RawQuery = query.Encode()
+	var reqBody io.Reader
+	if rp.body != "" {
+		reqBody = strings.NewReader(rp.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, rp.method, u.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if rp.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if rp.authHeader != "" {
+		req.Header.Set("Authorization", rp.authHeader)
+	}
+	resp, body, err := doRequest(ctx, rp.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// hosts 从响应中取出全部host，字段不存在或不是字符串的记录以及不需要的类型会被跳过
+func (rp *RESTProvider) hosts(body []byte) ([]string, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	v, err := lookupPath(doc, rp.recordsPath)
+	if err != nil {
+		return nil, fmt.Errorf("recordsPath %s: %w", rp.recordsPath, err)
+	}
+	records, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("recordsPath %s is not an array", rp.recordsPath)
+	}
+	hosts := make([]string, 0, len(records))
+	for _, record := range records {
+		if rp.typeField != "" {
+			v, err := lookupPath(record, rp.typeField)
+			if t, ok := v.(string); err != nil || !ok || !slices.Contains(rp.recordTypes, strings.ToUpper(t)) {
+				continue
+			}
+		}
+		v, err := lookupPath(record, rp.hostField)
+		if err != nil {
+			slog.Debug("skip record", "provider", rest, "url", rp.url, "error", err)
+			continue
+		}
+		if host, ok := v.(string); ok && host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+func (rp *RESTProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", rest, "url", rp.url)
+	var body []byte
+	err := withRetry(ctx, rp.defaults, func() (err error) {
+		body, err = rp.fetch(ctx)
+		return err
+	})
+	if err != nil {
+		ctxLogger(ctx).Warn("fetch failed exceed max retry", "retry", rp.defaults.retries(), "error", err)
+		return
+	}
+	hosts, err := rp.hosts(body)
+	if err != nil {
+		ctxLogger(ctx).Warn("parse body failed", "error", err)
+		return
+	}
+	for _, host := range hosts {
+		out <- host
+	}
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awsroute53 "github.com/aws/aws-sdk-go-v2/service/route53"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+func newRoute53Provider(keyId, keySecret, region string, zoneIds, recordTypes []string, defaults ProviderDefaults) *Route53Provider {
+	config := aws.Config{
+		Region:      region,
+		Credentials: credentials.NewStaticCredentialsProvider(keyId, keySecret, ""),
+		// 使用DefaultTransport，与其他provider一样使用SetProxy配置的代理
+		HTTPClient: &http.Client{},
+	}
+	types := make(map[awstypes.RRType]bool, len(recordTypes))
+	for _, t := range recordTypes {
+		types[awstypes.RRType(t)] = true
+	}
+	return &Route53Provider{
+		client:      awsroute53.NewFromConfig(config),
+		zoneIds:     zoneIds,
+		recordTypes: types,
+		defaults:    defaults,
+	}
+}
+
+type Route53Provider struct {
+	client      *awsroute53.Client
+	zoneIds     []string
+	recordTypes map[awstypes.RRType]bool
+	defaults    ProviderDefaults
+}
+
+// fetchWithRetry 获取一页记录，返回下一页的起始位置，没有下一页时返回nil
+func (rp *Route53Provider) fetchWithRetry(ctx context.Context, input *awsroute53.ListResourceRecordSetsInput, out chan<- string) (*awsroute53.ListResourceRecordSetsInput, error) {
+	var resp *awsroute53.ListResourceRecordSetsOutput
+	err := withRetry(ctx, rp.defaults, func() error {
+		return providerTask(ctx, func() (err error) {
+			start := time.Now()
+			resp, err = rp.client.ListResourceRecordSets(ctx, input)
+			ctxLogger(ctx).Debug("provider request", "action", "ListResourceRecordSets", "elapsed", time.Since(start), "error", err)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range resp.ResourceRecordSets {
+		if !rp.recordTypes[record.Type] {
+			continue
+		}
+		out <- route53RecordName(aws.ToString(record.Name))
+	}
+	if !resp.IsTruncated {
+		return nil, nil
+	}
+	return &awsroute53.ListResourceRecordSetsInput{
+		HostedZoneId:          input.HostedZoneId,
+		StartRecordName:       resp.NextRecordName,
+		StartRecordType:       resp.NextRecordType,
+		StartRecordIdentifier: resp.NextRecordIdentifier,
+	}, nil
+}
+
+func (rp *Route53Provider) getRecords(ctx context.Context, zoneId string, out chan<- string) {
+	input := &awsroute53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneId)}
+	ctx = withLogAttrs(ctx, "provider", route53, "zone", zoneId)
+	var err error
+	for page := 1; input != nil; page++ {
+		input, err = rp.fetchWithRetry(withLogAttrs(ctx, "page", page), input, out)
+		if err != nil {
+			ctxLogger(ctx).Warn("get hosted zone records failed", "page", page, "error", err)
+			return
+		}
+	}
+}
+
+func (rp *Route53Provider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, zoneId := range rp.zoneIds {
+		wg.Add(1)
+		go func(zoneId string) {
+			defer wg.Done()
+			rp.getRecords(ctx, zoneId, out)
+		}(strings.TrimSpace(zoneId))
+	}
+	wg.Wait()
+}
+
+// route53RecordName Route53返回的是带结尾点的FQDN，且泛域名的*被转义为\052
+func route53RecordName(name string) string {
+	name = strings.TrimSuffix(name, ".")
+	return strings.ReplaceAll(name, `\052`, "*")
+}
@@ -1,11 +1,1127 @@
 package pkg
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	alidns20150109 "github.com/alibabacloud-go/alidns-20150109/v4/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/miekg/dns"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/time/rate"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestAliyunProvider_GetAllRecords(t *testing.T) {
 }
 
+// fakeAliyunClient 按页返回记录，failures中的页在前n次请求时返回错误
+type fakeAliyunClient struct {
+	mu       sync.Mutex
+	total    int64
+	failures map[int64]int
+	calls    map[int64]int
+}
+
+func (fc *fakeAliyunClient) DescribeDomainRecordsWithOptions(request *alidns20150109.DescribeDomainRecordsRequest, _ *util.RuntimeOptions) (*alidns20150109.DescribeDomainRecordsResponse, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	page, size := *request.PageNumber, *request.PageSize
+	fc.calls[page]++
+	if fc.failures[page] > 0 {
+		fc.failures[page]--
+		return nil, errors.New("throttled")
+	}
+	records := make([]*alidns20150109.DescribeDomainRecordsResponseBodyDomainRecordsRecord, 0)
+	for i := (page - 1) * size; i < page*size && i < fc.total; i++ {
+		records = append(records, &alidns20150109.DescribeDomainRecordsResponseBodyDomainRecordsRecord{RR: tea.String(fmt.Sprintf("r%d", i))})
+	}
+	return &alidns20150109.DescribeDomainRecordsResponse{
+		StatusCode: tea.Int32(http.StatusOK),
+		Body: &alidns20150109.DescribeDomainRecordsResponseBody{
+			TotalCount:    tea.Int64(fc.total),
+			DomainRecords: &alidns20150109.DescribeDomainRecordsResponseBodyDomainRecords{Record: records},
+		},
+	}, nil
+}
+
+func collectAliyunRecords(client aliyunClient) []string {
+	return collectAliyunRecordsWithLimit(client, rate.Inf)
+}
+
+func collectAliyunRecordsWithLimit(client aliyunClient, qps rate.Limit) []string {
+	ap := &AliyunProvider{client: client, domains: []string{"example.com"}, recordTypes: []string{"A"}, limiter: rate.NewLimiter(qps, 1), defaults: fastRetry}
+	out := make(chan string, 1000)
+	ap.GetAllRecords(context.Background(), out)
+	close(out)
+	records := make([]string, 0)
+	for record := range out {
+		records = append(records, record)
+	}
+	sort.Strings(records)
+	return records
+}
+
+func TestAliyunProvider_PageErrorRetried(t *testing.T) {
+	client := &fakeAliyunClient{total: 250, failures: map[int64]int{2: 1}, calls: map[int64]int{}}
+	records := collectAliyunRecords(client)
+	if len(records) != 250 {
+		t.Fatalf("expected 250 records, got %d", len(records))
+	}
+	if client.calls[2] != 2 || client.calls[3] != 1 {
+		t.Errorf("unexpected calls %v", client.calls)
+	}
+}
+
+func TestAliyunProvider_FailedPageRequeued(t *testing.T) {
+	// 第2页在内部重试用尽后失败，本轮结束后重新获取成功
+	client := &fakeAliyunClient{total: 250, failures: map[int64]int{2: maxRetry}, calls: map[int64]int{}}
+	records := collectAliyunRecords(client)
+	if len(records) != 250 {
+		t.Fatalf("expected 250 records, got %d", len(records))
+	}
+	if client.calls[2] != maxRetry+1 {
+		t.Errorf("unexpected calls %v", client.calls)
+	}
+}
+
+// growingAliyunClient 第1页之后记录总数增加，模拟分页期间新增记录
+type growingAliyunClient struct {
+	fakeAliyunClient
+}
+
+func (gc *growingAliyunClient) DescribeDomainRecordsWithOptions(request *alidns20150109.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns20150109.DescribeDomainRecordsResponse, error) {
+	resp, err := gc.fakeAliyunClient.DescribeDomainRecordsWithOptions(request, runtime)
+	if *request.PageNumber == 1 {
+		gc.mu.Lock()
+		gc.total = 350
+		gc.mu.Unlock()
+	}
+	return resp, err
+}
+
+func TestAliyunProvider_RateLimit(t *testing.T) {
+	client := &fakeAliyunClient{total: 250, failures: map[int64]int{}, calls: map[int64]int{}}
+	start := time.Now()
+	if records := collectAliyunRecordsWithLimit(client, 20); len(records) != 250 {
+		t.Fatalf("expected 250 records, got %d", len(records))
+	}
+	// 3次请求，第1次不等待，之后每次间隔50ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("requests were not rate limited, took %s", elapsed)
+	}
+}
+
+func TestAliyunProvider_TotalCountGrows(t *testing.T) {
+	client := &growingAliyunClient{fakeAliyunClient{total: 250, failures: map[int64]int{}, calls: map[int64]int{}}}
+	if records := collectAliyunRecords(client); len(records) != 350 {
+		t.Fatalf("expected 350 records, got %d", len(records))
+	}
+}
+
 func TestFileProvider_GetAllRecords(t *testing.T) {
 }
+
+func TestRoute53RecordName(t *testing.T) {
+	cases := map[string]string{
+		"www.example.com.":  "www.example.com",
+		`\052.example.com.`: "*.example.com",
+		"example.com":       "example.com",
+	}
+	for name, want := range cases {
+		if got := route53RecordName(name); got != want {
+			t.Errorf("route53RecordName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWriteHTTPListBody(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain", "a.com\n\n# comment\nb.com:8443\n"},
+		{"application/json; charset=utf-8", `["a.com", "", "b.com:8443"]`},
+	}
+	for _, c := range cases {
+		out := make(chan string, 10)
+		if err := writeHTTPListBody(c.contentType, []byte(c.body), out); err != nil {
+			t.Fatal(err)
+		}
+		close(out)
+		hosts := make([]string, 0)
+		for host := range out {
+			hosts = append(hosts, host)
+		}
+		if len(hosts) != 2 || hosts[0] != "a.com" || hosts[1] != "b.com:8443" {
+			t.Errorf("%s: got %v", c.contentType, hosts)
+		}
+	}
+}
+
+func TestHTTPListProvider_GetAllRecords(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("a.com\nb.com:8443\n"))
+	}))
+	defer srv.Close()
+	out := make(chan string, 10)
+	newHTTPListProvider(srv.URL, "", fastRetry).GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	if attempts != 2 || strings.Join(hosts, ",") != "a.com,b.com:8443" {
+		t.Errorf("unexpected hosts %v after %d attempts", hosts, attempts)
+	}
+}
+
+func TestParseZoneFile(t *testing.T) {
+	zone := `$ORIGIN test.net.
+$TTL 3600
+@	IN	SOA	ns1.test.net. admin.test.net. (
+		2024010101 ; serial
+		7200 3600 1209600 3600 )
+	IN	NS	ns1.test.net.
+@		IN	A	192.0.2.1
+www  IN CNAME example.com.
+api	300	IN	AAAA	2001:db8::1
+	IN	A	192.0.2.2 ; same owner
+mail.other.org.	IN	A	192.0.2.3
+txt	IN	TXT	"v=spf1 -all"
+`
+	if !isZoneFile(zone) {
+		t.Fatal("zone file not detected")
+	}
+	if isZoneFile("a.com\nb.com\n") {
+		t.Fatal("plain host list detected as zone file")
+	}
+	want := []string{"test.net", "www.test.net", "api.test.net", "api.test.net", "mail.other.org"}
+	got := parseZoneFile(zone, nil)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want = []string{"api.test.net"}
+	if got = parseZoneFile(zone, []string{"AAAA"}); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("AAAA only: got %v, want %v", got, want)
+	}
+}
+
+func TestParseRecordTypes(t *testing.T) {
+	got := parseRecordTypes(" a, aaaa ,,CNAME")
+	if strings.Join(got, ",") != "A,AAAA,CNAME" {
+		t.Errorf("unexpected record types %v", got)
+	}
+	if parseRecordTypes("") != nil {
+		t.Error("empty value should return nil")
+	}
+}
+
+type staticProvider []string
+
+func (sp staticProvider) GetAllRecords(_ context.Context, out chan<- string) {
+	for _, host := range sp {
+		out <- host
+	}
+}
+
+func TestGetHosts(t *testing.T) {
+	out := make(chan Host, 10)
+	if n := GetHosts(context.Background(), "static", staticProvider{"a.com", "B.com.", "", "@", "*.C.com", "*.d.com/10.0.0.1"}, 30, out); n != 4 {
+		t.Errorf("expected 4 hosts, got %d", n)
+	}
+	if n := GetHosts(context.Background(), "empty", staticProvider{"", "@", "*", "*:8443"}, 30, out); n != 0 {
+		t.Errorf("expected no hosts, got %d", n)
+	}
+	close(out)
+	hosts := make([]Host, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	want := []Host{
+		{Host: "a.com", WarnDays: 30},
+		{Host: "b.com", WarnDays: 30},
+		{Host: "*.c.com", WarnDays: 30, Wildcard: true},
+		{Host: "*.d.com/10.0.0.1", WarnDays: 30, Wildcard: true},
+	}
+	if len(hosts) != len(want) {
+		t.Fatalf("got %v", hosts)
+	}
+	for i := range want {
+		if hosts[i] != want[i] {
+			t.Errorf("host %d = %+v, want %+v", i, hosts[i], want[i])
+		}
+	}
+}
+
+func TestRecordName(t *testing.T) {
+	cases := []struct {
+		rr, domain, want string
+	}{
+		{"www", "example.com", "www.example.com"},
+		{"@", "example.com", "example.com"},
+		{"", "example.com.", "example.com"},
+		{"*", "example.com", "*.example.com"},
+		{"api.example.com.", "example.com", "api.example.com"},
+		{"api.Example.com", "example.com", "api.Example.com"},
+		{"example.com", "example.com", "example.com"},
+		{"a.b", "example.com", "a.b.example.com"},
+	}
+	for _, c := range cases {
+		if got := recordName(c.rr, c.domain); got != c.want {
+			t.Errorf("recordName(%q, %q) = %q, want %q", c.rr, c.domain, got, c.want)
+		}
+	}
+}
+
+// pagedProvider 与Aliyun一样在协程中获取后续分页，用于验证返回时所有分页都已写入
+type pagedProvider struct {
+	pages int
+}
+
+func (pp pagedProvider) GetAllRecords(_ context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for page := 1; page <= pp.pages; page++ {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(page) * 10 * time.Millisecond)
+			out <- fmt.Sprintf("page%d.example.com", page)
+		}(page)
+	}
+	wg.Wait()
+}
+
+func TestGetHosts_WaitsForPages(t *testing.T) {
+	out := make(chan Host, 10)
+	GetHosts(context.Background(), "paged", pagedProvider{pages: 3}, 10, out)
+	// GetHosts返回后才关闭out，写入未完成时这里会panic
+	close(out)
+	if len(out) != 3 {
+		t.Fatalf("expected 3 hosts after GetHosts returned, got %d", len(out))
+	}
+}
+
+func TestK8sIngressProvider_GetAllRecords(t *testing.T) {
+	var paths []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		paths = append(paths, r.URL.Path+"?"+r.URL.Query().Get("continue"))
+		if r.URL.Query().Get("continue") == "" {
+			w.Write([]byte(`{"metadata":{"continue":"next"},"items":[
+				{"spec":{"rules":[{"host":"a.example.com"},{"host":"*.example.com"},{}],"tls":[{"hosts":["a.example.com","b.example.com"]}]}}]}`))
+			return
+		}
+		w.Write([]byte(`{"metadata":{},"items":[{"spec":{"rules":[{"host":"b.example.com"},{"host":"c.example.com"}]}}]}`))
+	}))
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	data := fmt.Sprintf(`apiVersion: v1
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    token: token
+`, srv.URL, base64.StdEncoding.EncodeToString(ca))
+	if err := os.WriteFile(kubeconfig, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan string, 10)
+	newK8sIngressProvider(kubeconfig, "", []string{"web"}, fastRetry).GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	if strings.Join(hosts, ",") != "a.example.com,*.example.com,b.example.com,c.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	want := "/apis/networking.k8s.io/v1/namespaces/web/ingresses?,/apis/networking.k8s.io/v1/namespaces/web/ingresses?next"
+	if strings.Join(paths, ",") != want {
+		t.Errorf("unexpected requests %v", paths)
+	}
+	if scopes := newK8sIngressProvider("", "", []string{"web", "*"}, fastRetry).scopes(); len(scopes) != 1 || scopes[0] != "" {
+		t.Errorf("unexpected scopes %v", scopes)
+	}
+
+	// exec插件不支持，应返回错误而不是匿名访问
+	data = strings.Replace(data, "    token: token\n", "    exec:\n      command: aws\n", 1)
+	if err := os.WriteFile(kubeconfig, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubeconfigClient(kubeconfig, ""); err == nil || !strings.Contains(err.Error(), "unsupported kubeconfig auth exec") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRESTProvider_GetAllRecords(t *testing.T) {
+	var method, query, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		method, query, body = r.Method, r.URL.RawQuery, string(data)
+		w.Write([]byte(`{"status":"SUCCESS","data":{"records":[
+			{"name":"www.example.com","type":"A"},
+			{"name":"api.example.com","type":"CNAME"},
+			{"name":"mail.example.com","type":"MX"},
+			{"type":"A"},
+			{"name":42,"type":"A"}]}}`))
+	}))
+	defer srv.Close()
+	out := make(chan string, 10)
+	rp := newRESTProvider(srv.URL+"/records?a=1", "post", `{"apikey":"k"}`, "Bearer token", map[string]string{"domain": "example.com"},
+		"data.records", "name", "type", defaultRecordTypes, ProviderDefaults{MaxRetry: 1})
+	rp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	if strings.Join(hosts, ",") != "www.example.com,api.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if method != http.MethodPost || query != "a=1&domain=example.com" || body != `{"apikey":"k"}` {
+		t.Errorf("unexpected request %s %q %q", method, query, body)
+	}
+
+	// hostField为空时数组元素就是host，typeField为空时不过滤
+	rp = newRESTProvider("", "", "", "", nil, "0.hosts", "", "", defaultRecordTypes, ProviderDefaults{})
+	if rp.method != http.MethodGet {
+		t.Errorf("unexpected default method %s", rp.method)
+	}
+	if hosts, err := rp.hosts([]byte(`[{"hosts":["a.com","b.com"]}]`)); err != nil || strings.Join(hosts, ",") != "a.com,b.com" {
+		t.Errorf("unexpected hosts %v, error %v", hosts, err)
+	}
+	if _, err := rp.hosts([]byte(`{"hosts":"a.com"}`)); err == nil {
+		t.Error("expected error for invalid recordsPath")
+	}
+}
+
+func TestAXFRProvider_GetAllRecords(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("transfer-secret"))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := func(s string) dns.RR {
+		r, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	soa := rr("example.com. 3600 IN SOA ns1.example.com. admin.example.com. 1 3600 600 86400 60")
+	mux := dns.NewServeMux()
+	mux.HandleFunc("example.com.", func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.IsTsig() == nil || w.TsigStatus() != nil {
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeRefused)
+			w.WriteMsg(m)
+			return
+		}
+		// 分为多个消息发送
+		ch := make(chan *dns.Envelope)
+		tr := new(dns.Transfer)
+		go func() {
+			ch <- &dns.Envelope{RR: []dns.RR{soa, rr("www.example.com. 60 IN A 192.0.2.1"), rr("www.example.com. 60 IN AAAA 2001:db8::1")}}
+			ch <- &dns.Envelope{RR: []dns.RR{rr("api.example.com. 60 IN CNAME www.example.com."), rr("*.example.com. 60 IN A 192.0.2.2"), rr("example.com. 60 IN MX 10 mail.example.com.")}}
+			ch <- &dns.Envelope{RR: []dns.RR{soa}}
+			close(ch)
+		}()
+		tr.TsigSecret = map[string]string{"transfer-key.": secret}
+		_ = tr.Out(w, req, ch)
+		w.Hijack()
+	})
+	srv := &dns.Server{Listener: ln, Handler: mux, TsigSecret: map[string]string{"transfer-key.": secret}}
+	go srv.ActivateAndServe()
+	defer srv.Shutdown()
+
+	out := make(chan string, 10)
+	newAXFRProvider(ln.Addr().String(), []string{"example.com"}, nil, "transfer-key", secret, "hmac-sha256", fastRetry).GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	if strings.Join(hosts, ",") != "www.example.com,api.example.com,*.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	// 没有TSIG时服务端拒绝传送
+	out = make(chan string, 10)
+	newAXFRProvider(ln.Addr().String(), []string{"example.com"}, nil, "", "", "hmac-sha256", fastRetry).GetAllRecords(context.Background(), out)
+	close(out)
+	if len(out) != 0 {
+		t.Errorf("expected no hosts without tsig, got %d", len(out))
+	}
+}
+
+func TestWestDigitalProvider_GBK(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		resp, _ := simplifiedchinese.GBK.NewEncoder().String(`{"code":200,"body":{"pagecount":1,"items":[
+			{"hostname":"邮件","pause":0},
+			{"hostname":"www","pause":"0"},
+			{"hostname":"old","pause":1}]}}`)
+		w.Header().Set("Content-Type", "application/json;charset=GBK")
+		w.Write([]byte(resp))
+	}))
+	defer srv.Close()
+	wd := newWestDigitalProvider("key", []string{"中文.cn"}, []string{"A"}, 1000, 1, ProviderDefaults{MaxRetry: 1})
+	wd.url = srv.URL
+	out := make(chan string, 10)
+	wd.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	if strings.Join(hosts, ",") != "xn--5nq051n.xn--fiq228c.cn,www.xn--fiq228c.cn" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	// 中文 in GBK is D6D0 CEC4
+	if !strings.Contains(body, "domain=%D6%D0%CE%C4.cn") {
+		t.Errorf("domain not encoded as GBK: %s", body)
+	}
+}
+
+func TestWestDigitalProvider_MalformedRecords(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":200,"body":{"pagecount":1,"items":[
+			{"hostname":"missing"},
+			{"hostname":"bool","pause":true},
+			{"hostname":"text","pause":"no"},
+			{"pause":0},
+			{"hostname":42,"pause":0},
+			{"hostname":"www","pause":0}]}}`))
+	}))
+	defer srv.Close()
+	wd := newWestDigitalProvider("key", []string{"example.com"}, []string{"A"}, 1000, 1, ProviderDefaults{MaxRetry: 1})
+	wd.url = srv.URL
+	out := make(chan string, 10)
+	wd.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	if strings.Join(hosts, ",") != "www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+}
+
+func TestWestDigitalProvider_Concurrency(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, peak, calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		calls++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte(`{"code":200,"body":{"pagecount":1,"items":[]}}`))
+	}))
+	defer srv.Close()
+	domains := []string{"a.com", "b.com", "c.com", "d.com", "e.com"}
+	wd := newWestDigitalProvider("key", domains, []string{"A", "CNAME"}, 1000, 2, ProviderDefaults{MaxRetry: 1})
+	wd.url = srv.URL
+	wd.GetAllRecords(context.Background(), make(chan string, 10))
+	if calls != 10 {
+		t.Errorf("expected 10 requests, got %d", calls)
+	}
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent requests, got %d", peak)
+	}
+}
+
+func TestProviderDefaults_Backoff(t *testing.T) {
+	pd := ProviderDefaults{RetryDelay: "100ms", MaxRetryDelay: "1s"}
+	for retry, limit := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 20 {
+			if delay := pd.backoff(retry); delay < limit/2 || delay > limit {
+				t.Fatalf("retry %d: delay %s not in [%s, %s]", retry, delay, limit/2, limit)
+			}
+		}
+	}
+	if delay := (ProviderDefaults{}).backoff(100); delay < defaultMaxRetryDelay/2 || delay > defaultMaxRetryDelay {
+		t.Errorf("expected delay capped at %s, got %s", defaultMaxRetryDelay, delay)
+	}
+}
+
+// fastRetry 测试中重试前只等待很短的时间
+var fastRetry = ProviderDefaults{RetryDelay: "1ms"}
+
+func TestWithRetry(t *testing.T) {
+	status := http.StatusUnauthorized
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	get := func() error {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return checkStatus(resp, nil)
+	}
+	// 认证失败不重试，只尝试一次时失败后不等待
+	for _, tc := range []struct {
+		status, retries, attempts int
+	}{{http.StatusUnauthorized, 3, 1}, {http.StatusForbidden, 3, 1}, {http.StatusInternalServerError, 1, 1}} {
+		status, attempts = tc.status, 0
+		done := make(chan error, 1)
+		go func() {
+			done <- withRetry(context.Background(), ProviderDefaults{MaxRetry: tc.retries, RetryDelay: "1h"}, get)
+		}()
+		select {
+		case err := <-done:
+			if err == nil || attempts != tc.attempts {
+				t.Errorf("status %d: got %d attempts, error %v", tc.status, attempts, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("status %d: waited after the last attempt", tc.status)
+		}
+	}
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}
+	if err := checkStatus(resp, nil); retryDelay(ProviderDefaults{}, err, 0) != 7*time.Second {
+		t.Errorf("expected to wait Retry-After, got %v", err)
+	}
+	// provider通常包装了原始错误
+	if err := fmt.Errorf("get records: %w", checkStatus(resp, nil)); retryDelay(ProviderDefaults{}, err, 0) != 7*time.Second {
+		t.Errorf("expected to wait Retry-After of the wrapped error, got %v", err)
+	}
+}
+
+func TestNS1Provider_GetAllRecords(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-NSONE-Key") != "key" || r.URL.Path != "/zones/example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// 第一次请求被限流
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"zone":"example.com","records":[
+			{"domain":"example.com","type":"A"},
+			{"domain":"www.example.com","type":"CNAME"},
+			{"domain":"example.com","type":"MX"},
+			{"domain":"v6.example.com","type":"AAAA"}]}`))
+	}))
+	defer srv.Close()
+	np := newNS1Provider("key", []string{" example.com"}, defaultRecordTypes, fastRetry)
+	np.url = srv.URL
+	out := make(chan string, 10)
+	np.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	if strings.Join(hosts, ",") != "example.com,www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 requests, got %d", calls)
+	}
+}
+
+func TestDigitalOceanProvider_GetAllRecords(t *testing.T) {
+	var mu sync.Mutex
+	throttled := false
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Path != "/domains/example.com/records" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		first := !throttled
+		throttled = true
+		mu.Unlock()
+		if first {
+			// 限额已经恢复，按retryDelay退避
+			w.Header().Set("RateLimit-Reset", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		switch r.URL.Query().Get("type") + r.URL.Query().Get("page") {
+		case "A":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/domains/example.com/records?type=A&page=2>; rel="next"`, srv.URL))
+			w.Write([]byte(`{"domain_records":[{"name":"@","type":"A"},{"name":"www","type":"A"}]}`))
+		case "A2":
+			w.Write([]byte(`{"domain_records":[{"name":"api","type":"A"}],"links":{}}`))
+		case "CNAME":
+			w.Write([]byte(`{"domain_records":[{"name":"blog","type":"CNAME"}],"links":{"pages":{"next":"` + srv.URL + `/domains/example.com/records?type=CNAME&page=2"}}}`))
+		case "CNAME2":
+			w.Write([]byte(`{"domain_records":[{"name":"shop","type":"CNAME"}]}`))
+		}
+	}))
+	defer srv.Close()
+	dp := newDigitalOceanProvider("token", []string{"example.com"}, defaultRecordTypes, fastRetry)
+	dp.url = srv.URL
+	out := make(chan string, 10)
+	dp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "api.example.com,blog.example.com,example.com,shop.example.com,www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+}
+
+func TestDigitalOceanRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	header := http.Header{"Ratelimit-Reset": {"1700000030"}}
+	if wait := digitalOceanRetryAfter(header, now); wait != 30*time.Second {
+		t.Errorf("expected 30s, got %s", wait)
+	}
+	if wait := digitalOceanRetryAfter(http.Header{}, now); wait != 0 {
+		t.Errorf("expected 0, got %s", wait)
+	}
+}
+
+func TestGetHosts_RequestLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"domain_records":[{"name":"www","type":"A"}]}`))
+	}))
+	defer srv.Close()
+	dp := newDigitalOceanProvider("token", []string{"example.com"}, []string{"A"}, fastRetry)
+	dp.url = srv.URL
+	out := make(chan Host, 10)
+	GetHosts(context.WithValue(context.Background(), loggerKey{}, logger), "do", dp, 10, out)
+	var request, finished map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("invalid log line %s: %v", line, err)
+		}
+		switch entry["msg"] {
+		case "provider request":
+			request = entry
+		case "provider enumeration finished":
+			finished = entry
+		}
+	}
+	if request == nil || finished == nil {
+		t.Fatalf("missing logs: %s", buf.String())
+	}
+	for key, want := range map[string]any{"providerName": "do", "provider": digitalOcean, "domain": "example.com", "type": "A", "page": float64(1), "status": float64(200)} {
+		if request[key] != want {
+			t.Errorf("expected %s=%v, got %v", key, want, request[key])
+		}
+	}
+	if request["enumeration"] == "" || request["enumeration"] != finished["enumeration"] {
+		t.Errorf("expected the same enumeration id, got %v and %v", request["enumeration"], finished["enumeration"])
+	}
+	if finished["records"] != float64(1) {
+		t.Errorf("expected 1 record, got %v", finished["records"])
+	}
+}
+
+func TestLinodeProvider_GetAllRecords(t *testing.T) {
+	var mu sync.Mutex
+	throttled := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.Query().Get("page") {
+		case "/domains?":
+			if r.Header.Get("X-Filter") != `{"domain":"example.com"}` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"data":[{"id":1,"domain":"example.com"}],"page":1,"pages":1}`))
+		case "/domains/2?":
+			w.Write([]byte(`{"id":2,"domain":"example.org"}`))
+		case "/domains/1/records?1":
+			mu.Lock()
+			first := !throttled
+			throttled = true
+			mu.Unlock()
+			if first {
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte(`{"data":[{"name":"","type":"A"},{"name":"www","type":"AAAA"},{"name":"","type":"MX"}],"page":1,"pages":2}`))
+		case "/domains/1/records?2":
+			w.Write([]byte(`{"data":[{"name":"blog","type":"CNAME"}],"page":2,"pages":2}`))
+		case "/domains/2/records?1":
+			w.Write([]byte(`{"data":[{"name":"api","type":"A"}],"page":1,"pages":1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	lp := newLinodeProvider("token", []string{"example.com", " 2"}, []string{"A", "AAAA", "CNAME"}, fastRetry)
+	lp.url = srv.URL
+	out := make(chan string, 10)
+	lp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "api.example.org,blog.example.com,example.com,www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+}
+
+func TestHuaweiDNSProvider_GetAllRecords(t *testing.T) {
+	var mu sync.Mutex
+	throttled := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "SDK-HMAC-SHA256 Access=ak, SignedHeaders=host;x-sdk-date, Signature=") ||
+			r.Header.Get("X-Sdk-Date") == "" || r.URL.Path != "/v2/recordsets" || q.Get("name") != "example.com." || q.Get("limit") != "2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		first := !throttled
+		throttled = true
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		switch q.Get("type") + q.Get("marker") {
+		case "A":
+			w.Write([]byte(`{"recordsets":[{"id":"1","name":"example.com.","type":"A","status":"ACTIVE","zone_name":"example.com."},` +
+				`{"id":"2","name":"www.example.com.example.org.","type":"A","status":"ACTIVE","zone_name":"example.com.example.org."}],"links":{"next":"more"}}`))
+		case "A2":
+			w.Write([]byte(`{"recordsets":[{"id":"3","name":"api.example.com.","type":"A","status":"DISABLE","zone_name":"example.com."}],"links":{}}`))
+		case "CNAME":
+			w.Write([]byte(`{"recordsets":[{"id":"4","name":"*.example.com.","type":"CNAME","status":"ACTIVE","zone_name":"example.com."}],"links":{}}`))
+		}
+	}))
+	defer srv.Close()
+	hp := newHuaweiDNSProvider("ak", "sk", "cn-north-4", []string{"example.com"}, defaultRecordTypes, 100, ProviderDefaults{PageSize: 2, RetryDelay: "1ms"})
+	hp.url = srv.URL
+	out := make(chan string, 10)
+	hp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "*.example.com,example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+}
+
+func TestJDCloudProvider_GetAllRecords(t *testing.T) {
+	var mu sync.Mutex
+	throttled := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "JDCLOUD2-HMAC-SHA256 Credential=ak/") ||
+			r.Header.Get("X-Jdcloud-Date") == "" || r.Header.Get("X-Jdcloud-Nonce") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		first := !throttled
+		throttled = true
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v1/regions/cn-north-1/domain" && q.Get("domainName") == "example.com":
+			w.Write([]byte(`{"result":{"dataList":[{"id":7,"domainName":"example.com"}]}}`))
+		case r.URL.Path == "/v1/regions/cn-north-1/domain/7/ResourceRecord" && q.Get("pageNumber") == "1":
+			w.Write([]byte(`{"result":{"dataList":[{"hostRecord":"@","type":"A"},{"hostRecord":"www","type":"CNAME"}],"totalPage":2}}`))
+		case r.URL.Path == "/v1/regions/cn-north-1/domain/7/ResourceRecord" && q.Get("pageNumber") == "2":
+			w.Write([]byte(`{"result":{"dataList":[{"hostRecord":"@","type":"MX"},{"hostRecord":"api","type":"A"}],"totalPage":2}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	jp := newJDCloudProvider("ak", "sk", "cn-north-1", []string{"example.com"}, defaultRecordTypes, 100, ProviderDefaults{PageSize: 2, RetryDelay: "1ms"})
+	jp.url = srv.URL
+	out := make(chan string, 10)
+	jp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "api.example.com,example.com,www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+}
+
+func TestHuaweiQuery(t *testing.T) {
+	if q := huaweiQuery(url.Values{"name": {"a b.com."}, "limit": {"2"}, "marker": {"x/y"}}); q != "limit=2&marker=x%2Fy&name=a%20b.com." {
+		t.Errorf("unexpected query %s", q)
+	}
+}
+
+func TestGandiProvider_GetAllRecords(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Apikey key" || r.URL.Path != "/domains/example.com/records" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// 第一次请求被限流
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`[
+			{"rrset_name":"@","rrset_type":"A","rrset_values":["192.0.2.1"]},
+			{"rrset_name":"www","rrset_type":"CNAME","rrset_values":["example.com."]},
+			{"rrset_name":"@","rrset_type":"MX","rrset_values":["10 mail.example.com."]}]`))
+	}))
+	defer srv.Close()
+	gp := newGandiProvider("", "key", []string{"example.com"}, defaultRecordTypes, fastRetry)
+	gp.url = srv.URL
+	out := make(chan string, 10)
+	gp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "example.com,www.example.com" || calls != 2 {
+		t.Errorf("unexpected hosts %v after %d calls", hosts, calls)
+	}
+	if auth := newGandiProvider("pat", "", nil, nil, ProviderDefaults{}).authorization; auth != "Bearer pat" {
+		t.Errorf("unexpected authorization %q", auth)
+	}
+}
+
+func TestVultrProvider_GetAllRecords(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" || r.URL.Path != "/domains/example.com/records" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// 第一次请求被限流
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"records":[{"name":"","type":"A"},{"name":"www","type":"CNAME"}],"meta":{"links":{"next":"page2"}}}`))
+		case "page2":
+			w.Write([]byte(`{"records":[{"name":"api","type":"A"},{"name":"","type":"MX"}],"meta":{"links":{"next":""}}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	vp := newVultrProvider("key", []string{"example.com"}, defaultRecordTypes, fastRetry)
+	vp.url = srv.URL
+	out := make(chan string, 10)
+	vp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "api.example.com,example.com,www.example.com" || calls != 3 {
+		t.Errorf("unexpected hosts %v after %d calls", hosts, calls)
+	}
+}
+
+func TestHetznerProvider_GetAllRecords(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Auth-API-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/zones" && r.URL.Query().Get("name") == "example.com":
+			w.Write([]byte(`{"zones":[{"id":"z1","name":"example.com"}]}`))
+		case r.URL.Path == "/zones/z2":
+			w.Write([]byte(`{"zone":{"id":"z2","name":"example.org"}}`))
+		case r.URL.Path == "/records" && r.URL.Query().Get("zone_id") == "z1":
+			if r.URL.Query().Get("page") == "1" {
+				w.Write([]byte(`{"records":[{"name":"@","type":"A"},{"name":"www","type":"CNAME"}],"meta":{"pagination":{"page":1,"last_page":2}}}`))
+				return
+			}
+			w.Write([]byte(`{"records":[{"name":"@","type":"MX"}],"meta":{"pagination":{"page":2,"last_page":2}}}`))
+		case r.URL.Path == "/records" && r.URL.Query().Get("zone_id") == "z2":
+			w.Write([]byte(`{"records":[{"name":"api","type":"A"}],"meta":{"pagination":{"page":1,"last_page":1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	hp := newHetznerProvider("token", []string{"example.com", " z2"}, defaultRecordTypes, fastRetry)
+	hp.url = srv.URL
+	out := make(chan string, 10)
+	hp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "api.example.org,example.com,www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+}
+
+func TestPowerDNSProvider_GetAllRecords(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" || r.URL.Path != "/api/v1/servers/localhost/zones/example.com." {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"name":"example.com.","rrsets":[
+			{"name":"example.com.","type":"A","records":[{"content":"192.0.2.1","disabled":false}]},
+			{"name":"www.example.com.","type":"CNAME","records":[{"content":"example.com.","disabled":false}]},
+			{"name":"old.example.com.","type":"A","records":[{"content":"192.0.2.2","disabled":true}]},
+			{"name":"example.com.","type":"MX","records":[{"content":"10 mail.example.com.","disabled":false}]}]}`))
+	}))
+	defer srv.Close()
+	pp := newPowerDNSProvider(srv.URL+"/api/v1/", "key", "localhost", []string{"example.com."}, defaultRecordTypes, fastRetry)
+	out := make(chan string, 10)
+	pp.GetAllRecords(context.Background(), out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "example.com,www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+}
+
+func TestDnspodProvider_GetAllRecords(t *testing.T) {
+	var dp *DnspodProvider
+	var mu sync.Mutex
+	offsets := make(map[string][]int64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-TC-Timestamp"), 10, 64)
+		if r.Method != http.MethodPost || r.Header.Get("X-TC-Action") != dnspodAction || r.Header.Get("X-TC-Version") != dnspodVersion ||
+			r.Header.Get("Authorization") != dp.sign(r.Host, body, timestamp) ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=id/"+time.Unix(timestamp, 0).UTC().Format("2006-01-02")+"/dnspod/tc3_request, ") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Domain, RecordType string
+			Offset, Limit      int64
+		}
+		if err := json.Unmarshal(body, &req); err != nil || req.Domain != "example.com" || req.Limit != 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		offsets[req.RecordType] = append(offsets[req.RecordType], req.Offset)
+		mu.Unlock()
+		switch fmt.Sprintf("%s%d", req.RecordType, req.Offset) {
+		case "A0":
+			w.Write([]byte(`{"Response":{"RecordCountInfo":{"TotalCount":3},"RecordList":[{"Name":"@","Type":"A","Status":"ENABLE"},{"Name":"www","Type":"A","Status":"ENABLE"}]}}`))
+		case "A2":
+			w.Write([]byte(`{"Response":{"RecordCountInfo":{"TotalCount":3},"RecordList":[{"Name":"api","Type":"A","Status":"DISABLE"}]}}`))
+		case "AAAA0":
+			w.Write([]byte(`{"Response":{"RecordCountInfo":{"TotalCount":3},"RecordList":[{"Name":"v6","Type":"AAAA","Status":"ENABLE"}]}}`))
+		case "AAAA2":
+			w.Write([]byte(`{"Response":{"Error":{"Code":"InternalError","Message":"try again"}}}`))
+		case "CNAME0":
+			w.Write([]byte(`{"Response":{"Error":{"Code":"ResourceNotFound.NoDataOfRecord","Message":"no record"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	dp = newDnspodProvider("id", "key", []string{" example.com"}, []string{"A", "AAAA", "CNAME"}, ProviderDefaults{PageSize: 2, RetryDelay: "1ms"})
+	dp.url = srv.URL
+	var buf bytes.Buffer
+	ctx := context.WithValue(context.Background(), loggerKey{}, slog.New(slog.NewTextHandler(&buf, nil)))
+	out := make(chan string, 10)
+	dp.GetAllRecords(ctx, out)
+	close(out)
+	hosts := make([]string, 0)
+	for host := range out {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if strings.Join(hosts, ",") != "example.com,v6.example.com,www.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	// 没有记录时不重试也不翻页
+	if len(offsets["CNAME"]) != 1 || len(offsets["A"]) != 2 {
+		t.Errorf("unexpected requests %v", offsets)
+	}
+	// 第二页及以后的失败同样记录日志
+	if !strings.Contains(buf.String(), "get domain page failed") || !strings.Contains(buf.String(), "InternalError") {
+		t.Errorf("expected page failure logged, got %s", buf.String())
+	}
+}
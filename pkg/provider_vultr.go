@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	vultrBaseURL = "https://api.vultr.com/v2"
+	// vultrPageSize per_page的最大值
+	vultrPageSize = 500
+)
+
+// VultrRecord name为相对域名的名称，apex记录为空
+type VultrRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// VultrRecordList links.next为下一页的cursor，最后一页为空
+type VultrRecordList struct {
+	Records []VultrRecord `json:"records"`
+	Meta    struct {
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	} `json:"meta"`
+}
+
+func newVultrProvider(apiKey string, domains, recordTypes []string, defaults ProviderDefaults) *VultrProvider {
+	return &VultrProvider{
+		url:         vultrBaseURL,
+		apiKey:      apiKey,
+		domains:     domains,
+		recordTypes: recordTypes,
+		defaults:    defaults,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// VultrProvider 按cursor分页读取每个域名的全部记录
+type VultrProvider struct {
+	url         string
+	apiKey      string
+	domains     []string
+	recordTypes []string
+	defaults    ProviderDefaults
+	client      *http.Client
+}
+
+func (vp *VultrProvider) listRecords(ctx context.Context, domain, cursor string) (*VultrRecordList, error) {
+	query := url.Values{"per_page": {strconv.Itoa(vultrPageSize)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, vp.url+"/domains/"+url.PathEscape(domain)+"/records?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+vp.apiKey)
+	resp, body, err := doRequest(ctx, vp.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatus(resp, body); err != nil {
+		return nil, err
+	}
+	list := new(VultrRecordList)
+	if err = json.Unmarshal(body, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (vp *VultrProvider) getRecords(ctx context.Context, domain string, out chan<- string) {
+	ctx = withLogAttrs(ctx, "provider", vultr, "domain", domain)
+	cursor := ""
+	for page := 1; ; page++ {
+		var list *VultrRecordList
+		err := withRetry(withLogAttrs(ctx, "page", page), vp.defaults, func() (err error) {
+			list, err = vp.listRecords(ctx, domain, cursor)
+			return err
+		})
+		if err != nil {
+			ctxLogger(ctx).Error("get record failed exceed max retry", "page", page, "retry", vp.defaults.retries(), "error", err)
+			return
+		}
+		for _, record := range list.Records {
+			if slices.Contains(vp.recordTypes, record.Type) {
+				out <- recordName(record.Name, domain)
+			}
+		}
+		if cursor = list.Meta.Links.Next; cursor == "" {
+			return
+		}
+	}
+}
+
+func (vp *VultrProvider) GetAllRecords(ctx context.Context, out chan<- string) {
+	var wg sync.WaitGroup
+	for _, domain := range vp.domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			vp.getRecords(ctx, domain, out)
+		}(strings.TrimSpace(domain))
+	}
+	wg.Wait()
+}
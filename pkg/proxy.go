@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	proxyMu sync.RWMutex
+	// proxyFunc 返回访问目标URL使用的代理，返回nil时直接连接，由SetProxy设置
+	proxyFunc = envProxyConfig().ProxyFunc()
+	// configuredProxy 配置文件中的proxy和noProxy，用于不使用net/http的SDK
+	configuredProxy, configuredNoProxy string
+)
+
+var proxyDefaultPorts = map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}
+
+// envProxyConfig 读取HTTPS_PROXY、HTTP_PROXY和NO_PROXY，未设置HTTPS_PROXY或HTTP_PROXY时使用ALL_PROXY
+func envProxyConfig() *httpproxy.Config {
+	config := httpproxy.FromEnvironment()
+	all := os.Getenv("ALL_PROXY")
+	if all == "" {
+		all = os.Getenv("all_proxy")
+	}
+	if config.HTTPSProxy == "" {
+		config.HTTPSProxy = all
+	}
+	if config.HTTPProxy == "" {
+		config.HTTPProxy = all
+	}
+	return config
+}
+
+// ParseProxy 检查配置的代理地址，支持http://、https://和socks5://
+func ParseProxy(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := proxyDefaultPorts[u.Scheme]; !ok || u.Host == "" {
+		return nil, fmt.Errorf("unsupported proxy %q, want http://, https:// or socks5://host:port", rawURL)
+	}
+	return u, nil
+}
+
+// SetProxy 在启动和重新加载配置时调用。rawURL为空时使用环境变量，否则TLS检查、provider和通知的
+// HTTP请求都通过rawURL连接，noProxy为逗号分隔的例外，格式与NO_PROXY相同。
+// 连接localhost和回环地址时总是不使用代理
+func SetProxy(rawURL, noProxy string) {
+	config := envProxyConfig()
+	if rawURL != "" {
+		config = &httpproxy.Config{HTTPProxy: rawURL, HTTPSProxy: rawURL, NoProxy: noProxy}
+	}
+	proxyMu.Lock()
+	proxyFunc = config.ProxyFunc()
+	configuredProxy, configuredNoProxy = rawURL, noProxy
+	proxyMu.Unlock()
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.Proxy = httpProxy
+		t.CloseIdleConnections()
+	}
+}
+
+// lookupProxy 返回访问target使用的代理
+func lookupProxy(target *url.URL) (*url.URL, error) {
+	proxyMu.RLock()
+	fn := proxyFunc
+	proxyMu.RUnlock()
+	return fn(target)
+}
+
+// httpProxy 用作http.Transport的Proxy，未指定Transport的http.Client使用的DefaultTransport由SetProxy设置
+func httpProxy(req *http.Request) (*url.URL, error) {
+	return lookupProxy(req.URL)
+}
+
+// proxyConfig 配置文件中的代理，只用于自带HTTP客户端的SDK，环境变量由SDK自己读取
+func proxyConfig() (string, string) {
+	proxyMu.RLock()
+	defer proxyMu.RUnlock()
+	return configuredProxy, configuredNoProxy
+}
+
+// dialProxy 通过代理建立到addr的TCP连接，http(s)代理使用CONNECT隧道，socks5代理由代理解析域名
+func dialProxy(ctx context.Context, d *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), proxyDefaultPorts[proxyURL.Scheme])
+	}
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if user := proxyURL.User; user != nil {
+			password, _ := user.Password()
+			auth = &proxy.Auth{User: user.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyAddr, auth, d)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	case "http", "https":
+		return dialConnect(ctx, d, proxyURL, proxyAddr, addr)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+}
+
+// dialConnect 向http(s)代理发送CONNECT请求，代理返回200后连接即为到addr的隧道
+func dialConnect(ctx context.Context, d *net.Dialer, proxyURL *url.URL, proxyAddr, addr string) (net.Conn, error) {
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: addr}, Host: addr, Header: make(http.Header)}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// STARTTLS的服务端会在隧道建立后立即发送问候，可能已被读入br
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (bc *bufferedConn) Read(p []byte) (int, error) {
+	return bc.r.Read(p)
+}
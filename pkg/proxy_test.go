@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// startConnectProxy 启动一个HTTP CONNECT代理，所有隧道都连接到upstream，返回代理地址和收到的请求
+func startConnectProxy(t *testing.T, upstream string) (string, <-chan *http.Request) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	requests := make(chan *http.Request, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				requests <- req
+				if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") == "" {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				backend, err := net.Dial("tcp", upstream)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer backend.Close()
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(backend, conn)
+				io.Copy(conn, backend)
+			}()
+		}
+	}()
+	return ln.Addr().String(), requests
+}
+
+func TestCheckHostHttps_Proxy(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"cert.example.test"}, now.Add(-time.Hour), now.Add(5*24*time.Hour+time.Hour))
+	addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	proxyAddr, requests := startConnectProxy(t, addr)
+
+	cases := []struct {
+		proxy string
+		want  string
+	}{
+		{"http://user:secret@" + proxyAddr, "expires in 5 days"},
+		{"http://" + proxyAddr, ""},
+	}
+	for _, c := range cases {
+		proxyURL, err := ParseProxy(c.proxy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make(chan CheckResult, 10)
+		sc := NewSimpleCheck(nil, out)
+		sc.RootCAs = x509.NewCertPool()
+		sc.RootCAs.AddCert(cert.Leaf)
+		sc.Retry = 1
+		sc.AlertOnFailure = true
+		sc.Proxy = func(*url.URL) (*url.URL, error) { return proxyURL, nil }
+		// 域名无法解析，只有通过代理才能连接
+		sc.checkHostHttps(context.Background(), "cert.example.test:443", 10)
+		req := <-requests
+		if req.Host != "cert.example.test:443" {
+			t.Errorf("proxy got CONNECT %q", req.Host)
+		}
+		results := collectResults(out, 100*time.Millisecond)
+		if c.want != "" && (len(results) != 1 || results[0].WarnMsg != c.want) {
+			t.Errorf("%s: expected %q, got %+v", c.proxy, c.want, results)
+		}
+		if c.want == "" && (len(results) != 1 || results[0].Severity != SeverityCritical) {
+			t.Errorf("%s: expected a failure, got %+v", c.proxy, results)
+		}
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	for _, raw := range []string{"http://proxy:3128", "https://proxy", "socks5://user:pw@proxy:1080"} {
+		if _, err := ParseProxy(raw); err != nil {
+			t.Errorf("ParseProxy(%q): %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"proxy:3128", "ftp://proxy", "http://"} {
+		if _, err := ParseProxy(raw); err == nil {
+			t.Errorf("ParseProxy(%q) should fail", raw)
+		}
+	}
+}
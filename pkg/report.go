@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jsonReport = "json"
+	csvReport  = "csv"
+)
+
+// reportFormat format为空时按扩展名判断，.csv为CSV，其他为JSON
+func reportFormat(path, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return csvReport
+	}
+	return jsonReport
+}
+
+// Report 收集一轮检查的全部结果，检查结束后整体写入JSON或CSV文件
+type Report struct {
+	mu      sync.Mutex
+	results []CheckResult
+}
+
+func NewReport() *Report {
+	return &Report{results: make([]CheckResult, 0)}
+}
+
+func (r *Report) Add(res CheckResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, res)
+}
+
+type reportFile struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Results   []reportResult `json:"results"`
+}
+
+type reportResult struct {
+	Host              string     `json:"host"`
+	WarnMsg           string     `json:"warnMsg"`
+	DaysRemaining     *int       `json:"daysRemaining,omitempty"`
+	NotAfter          *time.Time `json:"notAfter,omitempty"`
+	Issuer            string     `json:"issuer,omitempty"`
+	Subject           string     `json:"subject,omitempty"`
+	SerialNumber      string     `json:"serialNumber,omitempty"`
+	Severity          Severity   `json:"severity"`
+	SHA256Fingerprint string     `json:"sha256Fingerprint,omitempty"`
+}
+
+func (r *Report) encode(now time.Time) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rf := reportFile{Timestamp: now.UTC(), Results: make([]reportResult, 0, len(r.results))}
+	for _, res := range r.results {
+		rr := reportResult{Host: res.Host, WarnMsg: res.WarnMsg, Issuer: res.Issuer, Subject: res.Subject, SerialNumber: res.SerialNumber, Severity: res.Severity, SHA256Fingerprint: res.SHA256Fingerprint}
+		// 连接失败等与证书无关的结果没有剩余天数
+		if !res.NotAfter.IsZero() {
+			days, notAfter := res.DaysRemaining, res.NotAfter
+			rr.DaysRemaining, rr.NotAfter = &days, &notAfter
+		}
+		rf.Results = append(rf.Results, rr)
+	}
+	return json.MarshalIndent(rf, "", "  ")
+}
+
+// encodeCSV 每个结果一行，字段由encoding/csv负责转义
+func (r *Report) encodeCSV(now time.Time) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := [][]string{{"host", "warn_msg", "days_remaining", "not_after", "issuer", "checked_at"}}
+	checkedAt := now.UTC().Format(time.RFC3339)
+	for _, res := range r.results {
+		days, notAfter := "", ""
+		if !res.NotAfter.IsZero() {
+			days = strconv.Itoa(res.DaysRemaining)
+			notAfter = res.NotAfter.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, []string{res.Host, res.WarnMsg, days, notAfter, res.Issuer, checkedAt})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile 按format写入path，rotate为true时在文件名中加上时间保留每一轮的报告，否则覆盖写入
+func (r *Report) WriteFile(path, format string, rotate bool, now time.Time) (string, error) {
+	var data []byte
+	var err error
+	switch reportFormat(path, format) {
+	case jsonReport:
+		data, err = r.encode(now)
+	case csvReport:
+		data, err = r.encodeCSV(now)
+	default:
+		err = fmt.Errorf("unsupported report format %s", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	if rotate {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "-" + now.UTC().Format("20060102T150405Z") + ext
+	}
+	return path, writeFileAtomic(path, data)
+}
+
+// writeFileAtomic 先写临时文件再重命名，读取方不会看到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
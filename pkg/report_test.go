@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReport_WriteFile(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := NewReport()
+	r.Add(CheckResult{Host: "a.com:443", WarnMsg: "expires in 3 days", DaysRemaining: 3, NotAfter: now.AddDate(0, 0, 3), Issuer: "CN=R3"})
+	r.Add(CheckResult{Host: "b.com:443", WarnMsg: errConnRefused})
+
+	path, err := r.WriteFile(filepath.Join(dir, "report.json"), "", false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rf reportFile
+	if err = json.Unmarshal(data, &rf); err != nil {
+		t.Fatal(err)
+	}
+	if !rf.Timestamp.Equal(now) || len(rf.Results) != 2 {
+		t.Fatalf("unexpected report %s", data)
+	}
+	if rf.Results[0].DaysRemaining == nil || *rf.Results[0].DaysRemaining != 3 || rf.Results[0].Issuer != "CN=R3" {
+		t.Errorf("unexpected first result %+v", rf.Results[0])
+	}
+	if rf.Results[1].DaysRemaining != nil || rf.Results[1].NotAfter != nil {
+		t.Errorf("connection failure should have no expiry fields %+v", rf.Results[1])
+	}
+
+	path, err = r.WriteFile(filepath.Join(dir, "report.json"), "", true, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "report-20260102T030405Z.json" {
+		t.Errorf("unexpected rotated path %s", path)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("expected 2 files without leftovers, got %d", len(entries))
+	}
+}
+
+func TestReport_WriteCSV(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := NewReport()
+	r.Add(CheckResult{Host: "a.com:443", WarnMsg: "expires in 3 days", DaysRemaining: 3, NotAfter: now.AddDate(0, 0, 3), Issuer: "CN=R3,O=Let's Encrypt"})
+	r.Add(CheckResult{Host: "b.com:443", WarnMsg: errConnRefused})
+	path, err := r.WriteFile(filepath.Join(dir, "report.csv"), "", false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := `host,warn_msg,days_remaining,not_after,issuer,checked_at
+a.com:443,expires in 3 days,3,2026-01-05T03:04:05Z,"CN=R3,O=Let's Encrypt",2026-01-02T03:04:05Z
+b.com:443,connection refused,,,,2026-01-02T03:04:05Z
+`
+	if string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	smtpScheme  = "smtp"
+	imapScheme  = "imap"
+	pop3Scheme  = "pop3"
+	ftpScheme   = "ftp"
+	smtpsScheme = "smtps"
+	imapsScheme = "imaps"
+	pop3sScheme = "pop3s"
+	ftpsScheme  = "ftps"
+)
+
+// starttlsPorts 未指定scheme时，按端口判断需要先进行STARTTLS协商的协议
+var starttlsPorts = map[string]string{
+	"25":  smtpScheme,
+	"587": smtpScheme,
+	"143": imapScheme,
+	"110": pop3Scheme,
+	"21":  ftpScheme,
+}
+
+// schemePorts 指定了scheme但没有端口时使用的端口
+var schemePorts = map[string]string{
+	smtpScheme:  "25",
+	imapScheme:  "143",
+	pop3Scheme:  "110",
+	ftpScheme:   "21",
+	smtpsScheme: "465",
+	imapsScheme: "993",
+	pop3sScheme: "995",
+	ftpsScheme:  "990",
+}
+
+// implicitTLSSchemes 连接后直接开始TLS握手的协议，只用于确定端口和展示
+var implicitTLSSchemes = map[string]bool{
+	smtpsScheme: true,
+	imapsScheme: true,
+	pop3sScheme: true,
+	ftpsScheme:  true,
+}
+
+// splitScheme 拆分smtp://host:port形式的scheme前缀，没有前缀时scheme为空
+func splitScheme(host string) (scheme, rest string) {
+	if i := strings.Index(host, "://"); i > 0 {
+		return strings.ToLower(host[:i]), host[i+3:]
+	}
+	return "", host
+}
+
+// starttls 在明文连接上完成协议的STARTTLS协商，返回后即可在conn上开始TLS握手，超时由调用方设置
+func starttls(conn net.Conn, scheme string) error {
+	// textproto.Conn的缓冲只在协商阶段使用，服务端在协商完成前不会发送TLS数据
+	tc := textproto.NewConn(conn)
+	var err error
+	switch scheme {
+	case smtpScheme:
+		err = smtpStarttls(tc)
+	case imapScheme:
+		err = imapStarttls(tc)
+	case pop3Scheme:
+		err = pop3Starttls(tc)
+	case ftpScheme:
+		err = ftpStarttls(tc)
+	default:
+		err = fmt.Errorf("unsupported STARTTLS protocol %s", scheme)
+	}
+	if err != nil {
+		return fmt.Errorf("%s STARTTLS: %w", scheme, err)
+	}
+	return nil
+}
+
+func smtpStarttls(tc *textproto.Conn) error {
+	if _, _, err := tc.ReadResponse(220); err != nil {
+		return err
+	}
+	if err := tc.PrintfLine("EHLO check-certs"); err != nil {
+		return err
+	}
+	if _, _, err := tc.ReadResponse(250); err != nil {
+		return err
+	}
+	if err := tc.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	_, _, err := tc.ReadResponse(220)
+	return err
+}
+
+func imapStarttls(tc *textproto.Conn) error {
+	greeting, err := tc.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected greeting %q", greeting)
+	}
+	if err = tc.PrintfLine("a001 STARTTLS"); err != nil {
+		return err
+	}
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return err
+		}
+		// 跳过服务端的未标记响应，直到收到该命令的响应
+		if !strings.HasPrefix(line, "a001 ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a001 OK") {
+			return fmt.Errorf("unexpected response %q", line)
+		}
+		return nil
+	}
+}
+
+func pop3Starttls(tc *textproto.Conn) error {
+	greeting, err := tc.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("unexpected greeting %q", greeting)
+	}
+	if err = tc.PrintfLine("STLS"); err != nil {
+		return err
+	}
+	line, err := tc.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("unexpected response %q", line)
+	}
+	return nil
+}
+
+// ftpStarttls 按RFC 4217发送AUTH TLS，即显式FTPS
+func ftpStarttls(tc *textproto.Conn) error {
+	if _, _, err := tc.ReadResponse(220); err != nil {
+		return err
+	}
+	if err := tc.PrintfLine("AUTH TLS"); err != nil {
+		return err
+	}
+	_, _, err := tc.ReadResponse(234)
+	return err
+}
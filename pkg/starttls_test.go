@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSTARTTLSServer 启动一个只支持STARTTLS的SMTP、IMAP、POP3或FTP服务，协商完成后进行TLS握手
+func startSTARTTLSServer(t *testing.T, scheme string, cert tls.Certificate) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				switch scheme {
+				case smtpScheme:
+					conn.Write([]byte("220 stub ESMTP\r\n"))
+					r.ReadString('\n') // EHLO
+					conn.Write([]byte("250-stub\r\n250 STARTTLS\r\n"))
+					if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "STARTTLS") {
+						return
+					}
+					conn.Write([]byte("220 ready\r\n"))
+				case imapScheme:
+					conn.Write([]byte("* OK stub IMAP ready\r\n"))
+					if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "a001 STARTTLS") {
+						return
+					}
+					conn.Write([]byte("* CAPABILITY IMAP4rev1\r\na001 OK begin TLS\r\n"))
+				case pop3Scheme:
+					conn.Write([]byte("+OK stub POP3 ready\r\n"))
+					if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "STLS") {
+						return
+					}
+					conn.Write([]byte("+OK begin TLS\r\n"))
+				case ftpScheme:
+					conn.Write([]byte("220-stub FTP\r\n220 ready\r\n"))
+					if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "AUTH TLS") {
+						return
+					}
+					conn.Write([]byte("234 AUTH TLS OK\r\n"))
+				}
+				_ = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestCheckHostHttps_STARTTLS(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(0, 0, 5).Add(time.Hour))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	for _, scheme := range []string{smtpScheme, imapScheme, pop3Scheme, ftpScheme} {
+		_, port, _ := net.SplitHostPort(startSTARTTLSServer(t, scheme, cert))
+		out := make(chan CheckResult, 10)
+		sc := NewSimpleCheck(nil, out)
+		sc.RootCAs = pool
+		sc.checkHostHttps(context.Background(), scheme+"://localhost:"+port, 10)
+		results := collectResults(out, 100*time.Millisecond)
+		if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" {
+			t.Fatalf("%s: unexpected results %+v", scheme, results)
+		}
+		if results[0].Host != scheme+"://localhost:"+port {
+			t.Errorf("%s: unexpected host %s", scheme, results[0].Host)
+		}
+	}
+}
+
+func TestCheckHostHttps_ImplicitTLS(t *testing.T) {
+	now := time.Now()
+	cert := newTestCert(t, []string{"localhost"}, now.Add(-time.Hour), now.AddDate(0, 0, 5).Add(time.Hour))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	// 服务端不发送问候，连接后直接进行TLS握手
+	_, port, _ := net.SplitHostPort(startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}}))
+	for _, scheme := range []string{imapsScheme, smtpsScheme} {
+		out := make(chan CheckResult, 10)
+		sc := NewSimpleCheck(nil, out)
+		sc.RootCAs = pool
+		sc.checkHostHttps(context.Background(), scheme+"://localhost:"+port, 10)
+		results := collectResults(out, 100*time.Millisecond)
+		if len(results) != 1 || results[0].WarnMsg != "expires in 5 days" || results[0].Host != scheme+"://localhost:"+port {
+			t.Fatalf("%s: unexpected results %+v", scheme, results)
+		}
+	}
+}
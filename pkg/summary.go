@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Summary 统计一轮检查中每种问题涉及的host数量，检查完整结束后作为一条汇总通知发送
+type Summary struct {
+	mu       sync.Mutex
+	expiring map[string]bool
+	expired  map[string]bool
+	warnings map[string]bool // 过期之外的Warning及以上的告警，例如弱密钥、证书被吊销、证书与host不匹配
+}
+
+func NewSummary() *Summary {
+	return &Summary{expiring: make(map[string]bool), expired: make(map[string]bool), warnings: make(map[string]bool)}
+}
+
+// Add 统计一个结果，在告警被alertState抑制之前调用，汇总包含本轮所有的问题。
+// 无法检查的结果由Result的failures统计，这里不再计入
+func (s *Summary) Add(res CheckResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case res.failed:
+	case res.WarnMsg == errExpired:
+		s.expired[res.Host] = true
+	case isExpiring(res.WarnMsg):
+		s.expiring[res.Host] = true
+	case res.Severity >= SeverityWarning:
+		s.warnings[res.Host] = true
+	}
+}
+
+// isExpiring 是否为"expires in N days"或"expires in N hours"
+func isExpiring(warnMsg string) bool {
+	var n int
+	_, err := fmt.Sscanf(warnMsg, errExpiringSoon, &n)
+	if err != nil {
+		_, err = fmt.Sscanf(warnMsg, errExpiringShortly, &n)
+	}
+	return err == nil
+}
+
+// Result 汇总通知，checked为本轮检查的host数，failures为无法连接或握手失败的host数。
+// 汇总没有host，按其中最严重的问题设置Severity
+func (s *Summary) Result(checked, failures int64) CheckResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := fmt.Sprintf("Checked %d hosts: %d expiring, %d expired, %d errors", checked, len(s.expiring), len(s.expired), failures)
+	if len(s.warnings) > 0 {
+		msg += fmt.Sprintf(", %d other warnings", len(s.warnings))
+	}
+	severity := SeverityInfo
+	if len(s.expiring) > 0 || len(s.warnings) > 0 {
+		severity = SeverityWarning
+	}
+	if len(s.expired) > 0 || failures > 0 {
+		severity = SeverityCritical
+	}
+	return CheckResult{WarnMsg: msg + ".", Severity: severity}
+}
+
+// IsSummary 结果是否为Summary生成的汇总通知
+func IsSummary(res CheckResult) bool {
+	return res.Host == ""
+}
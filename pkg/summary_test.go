@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSummary_Result(t *testing.T) {
+	notAfter := time.Now().Add(5 * 24 * time.Hour)
+	s := NewSummary()
+	if res := s.Result(3, 0); res.WarnMsg != "Checked 3 hosts: 0 expiring, 0 expired, 0 errors." || res.Severity != SeverityInfo || !IsSummary(res) {
+		t.Errorf("unexpected summary %+v", res)
+	}
+	s.Add(CheckResult{Host: "a.com", WarnMsg: "expires in 5 days", Severity: SeverityWarning, NotAfter: notAfter})
+	// 同一个host的多个结果只统计一次
+	s.Add(CheckResult{Host: "a.com", WarnMsg: "expires in 5 days", Severity: SeverityWarning, NotAfter: notAfter})
+	s.Add(CheckResult{Host: "b.com", WarnMsg: "expires in 20 hours", Severity: SeverityCritical, NotAfter: notAfter})
+	s.Add(CheckResult{Host: "c.com", WarnMsg: errExpired, Severity: SeverityCritical, NotAfter: time.Now().Add(-time.Hour)})
+	s.Add(CheckResult{Host: "d.com", WarnMsg: "weak key: RSA 1024 bits", Severity: SeverityWarning, NotAfter: notAfter})
+	s.Add(CheckResult{Host: "e.com", WarnMsg: "missing or short HSTS max-age: missing", Severity: SeverityInfo, NotAfter: notAfter})
+	// 证书与host不匹配的结果没有NotAfter，同样计入其他告警
+	s.Add(CheckResult{Host: "g.com", WarnMsg: errHostname, Severity: SeverityWarning, Subject: "CN=other.com"})
+	// 无法检查的host只计入errors
+	s.Add(failureResult("f.com", syscall.ECONNREFUSED))
+	res := s.Result(412, 1)
+	if res.WarnMsg != "Checked 412 hosts: 2 expiring, 1 expired, 1 errors, 2 other warnings." {
+		t.Errorf("unexpected summary %q", res.WarnMsg)
+	}
+	if res.Severity != SeverityCritical {
+		t.Errorf("expected critical, got %s", res.Severity)
+	}
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// isZoneFile 包含$ORIGIN或SOA记录时按BIND zone文件解析
+func isZoneFile(contents string) bool {
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(stripZoneComment(line))
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.EqualFold(fields[0], "$ORIGIN") {
+			return true
+		}
+		for i := 0; i < len(fields)-1; i++ {
+			if strings.EqualFold(fields[i], "IN") && strings.EqualFold(fields[i+1], "SOA") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stripZoneComment(line string) string {
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// zoneLines 去掉注释并将括号内跨行的记录合并为一行，同时保留行首是否为空白的信息
+func zoneLines(contents string) []string {
+	lines := make([]string, 0)
+	var buf strings.Builder
+	depth := 0
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimRight(stripZoneComment(line), " \t\r")
+		if depth > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(line)
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		if depth > 0 {
+			continue
+		}
+		depth = 0
+		lines = append(lines, buf.String())
+		buf.Reset()
+	}
+	if buf.Len() > 0 {
+		lines = append(lines, buf.String())
+	}
+	return lines
+}
+
+// parseZoneFile 解析recordTypes类型的记录，recordTypes为空时解析A/AAAA/CNAME，返回不带结尾点的FQDN
+func parseZoneFile(contents string, recordTypes []string) []string {
+	if len(recordTypes) == 0 {
+		recordTypes = []string{"A", "AAAA", "CNAME"}
+	}
+	hosts := make([]string, 0)
+	origin := ""
+	owner := ""
+	for _, line := range zoneLines(contents) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "$") {
+			if strings.EqualFold(fields[0], "$ORIGIN") && len(fields) > 1 {
+				origin = strings.TrimSuffix(fields[1], ".")
+			}
+			continue
+		}
+		// 行首为空白时沿用上一条记录的名称
+		if line[0] != ' ' && line[0] != '\t' {
+			owner = fields[0]
+			fields = fields[1:]
+		}
+		recordType := zoneRecordType(fields)
+		if !slices.Contains(recordTypes, recordType) {
+			continue
+		}
+		hosts = append(hosts, zoneFQDN(owner, origin))
+	}
+	return hosts
+}
+
+// zoneRecordType 跳过可选的TTL和class，返回记录类型
+func zoneRecordType(fields []string) string {
+	for _, field := range fields {
+		if _, err := strconv.Atoi(field); err == nil {
+			continue
+		}
+		switch strings.ToUpper(field) {
+		case "IN", "CH", "HS":
+			continue
+		}
+		return strings.ToUpper(field)
+	}
+	return ""
+}
+
+func zoneFQDN(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case origin == "":
+		return name
+	}
+	return name + "." + origin
+}